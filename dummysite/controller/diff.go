@@ -0,0 +1,74 @@
+package main
+
+import (
+	"maps"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// The *Changed functions compare only the fields the controller sets, since the
+// API server fills in defaults (image pull policy, cluster IP, ...) that a full
+// comparison would always see as a difference. Unchanged resources are not
+// updated, which spares the API server and, for the ConfigMap, the pods.
+
+func configMapChanged(existing, desired *corev1.ConfigMap) bool {
+	return !maps.Equal(existing.Data, desired.Data)
+}
+
+func deploymentChanged(existing, desired *appsv1.Deployment) bool {
+	have, want := existing.Spec, desired.Spec
+	if have.Replicas == nil || *have.Replicas != *want.Replicas {
+		return true
+	}
+	if !maps.Equal(have.Template.Labels, want.Template.Labels) {
+		return true
+	}
+	if len(have.Template.Spec.Containers) != len(want.Template.Spec.Containers) ||
+		len(have.Template.Spec.Volumes) != len(want.Template.Spec.Volumes) {
+		return true
+	}
+	for i, w := range want.Template.Spec.Containers {
+		h := have.Template.Spec.Containers[i]
+		if h.Name != w.Name || h.Image != w.Image ||
+			!equality.Semantic.DeepEqual(h.Resources, w.Resources) ||
+			!equality.Semantic.DeepEqual(h.VolumeMounts, w.VolumeMounts) {
+			return true
+		}
+		if len(h.Ports) != len(w.Ports) {
+			return true
+		}
+		for j, port := range w.Ports {
+			if h.Ports[j].ContainerPort != port.ContainerPort {
+				return true
+			}
+		}
+	}
+	for i, w := range want.Template.Spec.Volumes {
+		h := have.Template.Spec.Volumes[i]
+		if h.Name != w.Name || h.ConfigMap == nil || h.ConfigMap.Name != w.ConfigMap.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func serviceChanged(existing, desired *corev1.Service) bool {
+	have, want := existing.Spec, desired.Spec
+	if have.Type != want.Type || !maps.Equal(have.Selector, want.Selector) || len(have.Ports) != len(want.Ports) {
+		return true
+	}
+	for i, w := range want.Ports {
+		h := have.Ports[i]
+		if h.Port != w.Port || h.TargetPort != w.TargetPort || h.Protocol != w.Protocol {
+			return true
+		}
+	}
+	return false
+}
+
+func ingressChanged(existing, desired *networkingv1.Ingress) bool {
+	return !equality.Semantic.DeepEqual(existing.Spec.Rules, desired.Spec.Rules)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// FetchPolicy controls which website_url targets the controller may fetch. It
+// exists so a DummySite can't be pointed at cluster-internal services (SSRF).
+type FetchPolicy struct {
+	Timeout      time.Duration
+	MaxRedirects int
+	// AllowedHosts, when non-empty, is the only set of hosts that may be fetched.
+	// An entry matches the host itself and its subdomains.
+	AllowedHosts []string
+	// DeniedHosts are never fetched, even if allowed.
+	DeniedHosts []string
+	// AllowPrivateNetworks permits loopback, private, link-local and similar
+	// addresses, which are blocked by default.
+	AllowPrivateNetworks bool
+}
+
+// fetchValidators are the cache validators a site returned for URL, kept in
+// status.fetch between reconciles.
+type fetchValidators struct {
+	URL          string
+	ETag         string
+	LastModified string
+}
+
+// fetchResult is the outcome of fetchHTML. HTML is empty when NotModified is set.
+type fetchResult struct {
+	HTML        string
+	Validators  fetchValidators
+	NotModified bool
+}
+
+// fetchValidatorsFromStatus reads status.fetch, returning zero validators if unset.
+func fetchValidatorsFromStatus(obj *unstructured.Unstructured) fetchValidators {
+	var v fetchValidators
+	v.URL, _, _ = unstructured.NestedString(obj.Object, "status", "fetch", "url")
+	v.ETag, _, _ = unstructured.NestedString(obj.Object, "status", "fetch", "etag")
+	v.LastModified, _, _ = unstructured.NestedString(obj.Object, "status", "fetch", "lastModified")
+	return v
+}
+
+// errDisallowedURL marks a fetch refused by the FetchPolicy. Retrying won't help,
+// so reconcile reports it in the status instead of requeueing.
+var errDisallowedURL = errors.New("URL not allowed")
+
+// isDisallowedURL reports whether err is a fetch refused by the FetchPolicy.
+func isDisallowedURL(err error) bool {
+	return errors.Is(err, errDisallowedURL)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip doesn't treat as
+// private but which is just as internal.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// checkURL rejects URLs whose scheme or host the policy doesn't allow. Addresses
+// are checked separately at dial time, after DNS resolution.
+func (p FetchPolicy) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q is not http or https", errDisallowedURL, u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: missing host", errDisallowedURL)
+	}
+	if matchesHost(host, p.DeniedHosts) {
+		return fmt.Errorf("%w: host %q is denied", errDisallowedURL, host)
+	}
+	if len(p.AllowedHosts) > 0 && !matchesHost(host, p.AllowedHosts) {
+		return fmt.Errorf("%w: host %q is not in the allowlist", errDisallowedURL, host)
+	}
+	return nil
+}
+
+// matchesHost reports whether host equals one of patterns or is a subdomain of one.
+func matchesHost(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(strings.ToLower(pattern), ".")
+		if host == pattern || strings.HasSuffix(host, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddr rejects non-public addresses unless private networks are allowed.
+func (p FetchPolicy) checkAddr(addr netip.Addr) error {
+	if p.AllowPrivateNetworks {
+		return nil
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: address %s is not public", errDisallowedURL, addr)
+	}
+	return nil
+}
+
+// newClient builds the HTTP client used to fetch sites. The address check runs in
+// the dialer's Control hook, i.e. on the resolved IP actually being connected to,
+// so DNS names that resolve to internal addresses are caught too.
+func (p FetchPolicy) newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return p.checkAddr(addrPort.Addr())
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would make the dial-time address check meaningless
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{
+		Timeout:   p.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > p.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", p.MaxRedirects)
+			}
+			return p.checkURL(req.URL)
+		},
+	}
+}
@@ -5,23 +5,34 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 )
 
@@ -33,24 +44,83 @@ var (
 	}
 )
 
+// dummySiteFinalizer keeps a DummySite around until the controller has cleaned up
+// anything it created outside the owner-reference cascade.
+const dummySiteFinalizer = "codegeek.com/dummysite-cleanup"
+
+// DummySite condition types reported in status.conditions
+const (
+	conditionHTMLFetched         = "HTMLFetched"
+	conditionDeploymentAvailable = "DeploymentAvailable"
+	conditionReady               = "Ready"
+)
+
+// defaultSiteImage serves the fetched HTML when a DummySite doesn't set spec.image
+const defaultSiteImage = "nginx:alpine"
+
+// imageReferencePattern loosely matches [registry[:port]/]path[:tag][@digest]. It
+// rejects obviously malformed references rather than fully validating them; the
+// kubelet reports anything subtler when it pulls the image.
+var imageReferencePattern = regexp.MustCompile(
+	`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?::[0-9]+)?(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+		`(?::[A-Za-z0-9_][A-Za-z0-9_.-]{0,127})?(?:@sha256:[a-f0-9]{64})?$`)
+
+// validateImage checks a spec.image value
+func validateImage(image string) error {
+	if strings.TrimSpace(image) == "" {
+		return fmt.Errorf("spec.image must not be empty")
+	}
+	if !imageReferencePattern.MatchString(image) {
+		return fmt.Errorf("spec.image %q is not a valid image reference", image)
+	}
+	return nil
+}
+
+// readinessRequeueInterval is how often a DummySite is re-checked while its
+// Deployment has no ready replica yet.
+const readinessRequeueInterval = 5 * time.Second
+
+// ControllerConfig holds the controller settings read from the environment.
+type ControllerConfig struct {
+	// Namespace to watch; empty watches all namespaces
+	Namespace string
+	// LabelSelector restricts which DummySites are handled; empty handles all
+	LabelSelector string
+	// DefaultResources apply to site containers unless spec.resources overrides them
+	DefaultResources corev1.ResourceRequirements
+	// Fetch limits which websites may be fetched and how
+	Fetch FetchPolicy
+	// Workers is how many DummySites are reconciled in parallel
+	Workers int
+	// ResyncPeriod is how often every DummySite is reconciled again even without
+	// changes, which undoes manual edits to the resources it manages
+	ResyncPeriod time.Duration
+}
+
 type Controller struct {
 	clientset     *kubernetes.Clientset
 	dynamicClient dynamic.Interface
 	informer      cache.SharedIndexInformer
+	queue         workqueue.TypedRateLimitingInterface[string]
+	config        ControllerConfig
+	httpClient    *http.Client
 }
 
-func NewController(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface) *Controller {
+// NewController creates a controller watching the DummySites selected by cfg.
+func NewController(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, cfg ControllerConfig) *Controller {
 	informer := cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-				return dynamicClient.Resource(dummySiteGVR).Namespace(corev1.NamespaceAll).List(context.TODO(), options)
+				options.LabelSelector = cfg.LabelSelector
+				return dynamicClient.Resource(dummySiteGVR).Namespace(cfg.Namespace).List(context.TODO(), options)
 			},
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-				return dynamicClient.Resource(dummySiteGVR).Namespace(corev1.NamespaceAll).Watch(context.TODO(), options)
+				options.LabelSelector = cfg.LabelSelector
+				return dynamicClient.Resource(dummySiteGVR).Namespace(cfg.Namespace).Watch(context.TODO(), options)
 			},
 		},
 		&unstructured.Unstructured{},
-		time.Minute*10,
+		cfg.ResyncPeriod,
 		cache.Indexers{},
 	)
 
@@ -58,6 +128,12 @@ func NewController(clientset *kubernetes.Clientset, dynamicClient dynamic.Interf
 		clientset:     clientset,
 		dynamicClient: dynamicClient,
 		informer:      informer,
+		config:        cfg,
+		httpClient:    cfg.Fetch.newClient(),
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "dummysites"},
+		),
 	}
 
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -71,6 +147,7 @@ func NewController(clientset *kubernetes.Clientset, dynamicClient dynamic.Interf
 
 func (c *Controller) Run(stopCh <-chan struct{}) {
 	defer klog.Info("Shutting down controller")
+	defer c.queue.ShutDown()
 
 	klog.Info("Starting DummySite controller")
 	go c.informer.Run(stopCh)
@@ -80,91 +157,332 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 		return
 	}
 
-	klog.Info("Controller synced and ready")
+	// The queue never hands the same key to two workers at once, so only
+	// independent DummySites are reconciled in parallel
+	var wg sync.WaitGroup
+	for range c.config.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait.Until(c.runWorker, time.Second, stopCh)
+		}()
+	}
+
+	klog.Infof("Controller synced and ready with %d workers", c.config.Workers)
 	<-stopCh
+
+	// Let in-flight reconciles finish before returning
+	c.queue.ShutDown()
+	wg.Wait()
+}
+
+// runWorker processes queued DummySite keys until the queue is shut down
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	obj, exists, err := c.informer.GetIndexer().GetByKey(key)
+	if err != nil {
+		klog.Errorf("Failed to get DummySite %s from cache: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	if !exists {
+		// Deleted since it was queued; nothing left to reconcile
+		c.queue.Forget(key)
+		return true
+	}
+
+	requeueAfter, err := c.reconcile(obj.(*unstructured.Unstructured))
+	if err != nil {
+		klog.Errorf("Failed to reconcile DummySite %s: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	if requeueAfter > 0 {
+		c.queue.AddAfter(key, requeueAfter)
+	}
+	return true
+}
+
+// enqueue adds the DummySite's namespace/name key to the work queue
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Errorf("Failed to get key for object: %v", err)
+		return
+	}
+	c.queue.Add(key)
 }
 
 func (c *Controller) handleAdd(obj interface{}) {
 	u := obj.(*unstructured.Unstructured)
 	klog.Infof("DummySite added: %s/%s", u.GetNamespace(), u.GetName())
-	c.reconcile(u)
+	c.enqueue(u)
 }
 
+// handleUpdate also receives the periodic resyncs, which are reconciled like any
+// other update so drift in the site's resources gets corrected
 func (c *Controller) handleUpdate(oldObj, newObj interface{}) {
+	old := oldObj.(*unstructured.Unstructured)
 	u := newObj.(*unstructured.Unstructured)
-	klog.Infof("DummySite updated: %s/%s", u.GetNamespace(), u.GetName())
-	c.reconcile(u)
+	if old.GetResourceVersion() == u.GetResourceVersion() {
+		klog.V(2).Infof("Resyncing DummySite %s/%s", u.GetNamespace(), u.GetName())
+	} else {
+		klog.Infof("DummySite updated: %s/%s", u.GetNamespace(), u.GetName())
+	}
+	c.enqueue(u)
 }
 
 func (c *Controller) handleDelete(obj interface{}) {
-	u := obj.(*unstructured.Unstructured)
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		klog.Errorf("Unexpected object in delete event: %T", obj)
+		return
+	}
 	klog.Infof("DummySite deleted: %s/%s", u.GetNamespace(), u.GetName())
+	// External cleanup already ran in finalize before the finalizer was removed;
 	// Kubernetes will handle cascade deletion of owned resources
 }
 
-func (c *Controller) reconcile(obj *unstructured.Unstructured) {
+// finalize cleans up a DummySite that is being deleted and then removes the
+// finalizer so the API server can delete it.
+func (c *Controller) finalize(ctx context.Context, obj *unstructured.Unstructured) error {
+	if !slices.Contains(obj.GetFinalizers(), dummySiteFinalizer) {
+		return nil
+	}
+
+	if err := c.cleanupExternal(ctx, obj); err != nil {
+		return fmt.Errorf("cleanup failed: %w", err)
+	}
+
+	obj = obj.DeepCopy()
+	obj.SetFinalizers(slices.DeleteFunc(obj.GetFinalizers(), func(f string) bool {
+		return f == dummySiteFinalizer
+	}))
+	_, err := c.dynamicClient.Resource(dummySiteGVR).Namespace(obj.GetNamespace()).Update(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
+
+// cleanupExternal releases resources that aren't owned by the DummySite and so
+// aren't removed by the owner-reference cascade. There are none yet.
+func (c *Controller) cleanupExternal(ctx context.Context, obj *unstructured.Unstructured) error {
+	klog.Infof("Cleaning up external resources for DummySite %s/%s", obj.GetNamespace(), obj.GetName())
+	return nil
+}
+
+// reconcile brings the site's resources in line with the DummySite. A non-nil error
+// retries the key with backoff; a positive duration requeues it after that long
+// even on success, while the site is still coming up.
+func (c *Controller) reconcile(obj *unstructured.Unstructured) (time.Duration, error) {
 	ctx := context.Background()
 	name := obj.GetName()
 	namespace := obj.GetNamespace()
 
-	// Extract website_url from spec
+	if obj.GetDeletionTimestamp() != nil {
+		if err := c.finalize(ctx, obj); err != nil {
+			return 0, fmt.Errorf("failed to finalize: %w", err)
+		}
+		return 0, nil
+	}
+
+	// Add the finalizer before creating anything; the resulting update event
+	// reconciles the site
+	if !slices.Contains(obj.GetFinalizers(), dummySiteFinalizer) {
+		obj = obj.DeepCopy()
+		obj.SetFinalizers(append(obj.GetFinalizers(), dummySiteFinalizer))
+		_, err := c.dynamicClient.Resource(dummySiteGVR).Namespace(namespace).Update(ctx, obj, metav1.UpdateOptions{})
+		if err != nil {
+			return 0, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+		return 0, nil
+	}
+
+	// Extract website_url from spec. A malformed object won't fix itself by
+	// retrying, so these are logged rather than returned.
 	spec, found, err := unstructured.NestedMap(obj.Object, "spec")
 	if err != nil || !found {
 		klog.Errorf("Failed to get spec: %v", err)
-		return
+		return 0, nil
 	}
 
 	websiteURL, found, err := unstructured.NestedString(spec, "website_url")
 	if err != nil || !found {
 		klog.Errorf("Failed to get website_url: %v", err)
-		return
+		return 0, nil
+	}
+
+	image, found, err := unstructured.NestedString(spec, "image")
+	if err != nil {
+		klog.Errorf("Failed to get image: %v", err)
+		return 0, nil
+	}
+	if !found {
+		image = defaultSiteImage
+	}
+	if err := validateImage(image); err != nil {
+		klog.Errorf("Invalid DummySite %s/%s: %v", namespace, name, err)
+		c.updateStatus(ctx, obj, "Error", "",
+			condition(conditionReady, false, "InvalidImage", err.Error()),
+		)
+		return 0, nil
+	}
+
+	resources, err := c.siteResources(spec)
+	if err != nil {
+		klog.Errorf("Invalid DummySite %s/%s: %v", namespace, name, err)
+		c.updateStatus(ctx, obj, "Error", "",
+			condition(conditionReady, false, "InvalidResources", err.Error()),
+		)
+		return 0, nil
 	}
 
 	klog.Infof("Reconciling DummySite %s/%s with URL: %s", namespace, name, websiteURL)
 
-	// Fetch HTML content
-	htmlContent, err := c.fetchHTML(websiteURL)
+	// Fetch HTML content, conditionally if the last fetch of this URL left validators
+	cached := fetchValidatorsFromStatus(obj)
+	if cached.URL == websiteURL {
+		// A 304 is only useful while the ConfigMap still holds the page
+		_, err := c.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name+"-html", metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			cached = fetchValidators{}
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to get ConfigMap: %w", err)
+		}
+	}
+	result, err := c.fetchHTML(websiteURL, cached)
+	if isDisallowedURL(err) {
+		klog.Errorf("Refusing to fetch %s for DummySite %s/%s: %v", websiteURL, namespace, name, err)
+		c.updateStatus(ctx, obj, "Error", "",
+			condition(conditionHTMLFetched, false, "URLNotAllowed", err.Error()),
+			condition(conditionReady, false, "URLNotAllowed", "website_url is not allowed by the controller's fetch policy"),
+		)
+		return 0, nil
+	}
 	if err != nil {
-		klog.Errorf("Failed to fetch HTML: %v", err)
-		c.updateStatus(ctx, namespace, name, "Error", "")
-		return
+		c.updateStatus(ctx, obj, "Error", "",
+			condition(conditionHTMLFetched, false, "FetchFailed", err.Error()),
+			condition(conditionReady, false, "FetchFailed", "The website could not be fetched"),
+		)
+		return 0, fmt.Errorf("failed to fetch HTML: %w", err)
+	}
+	fetched := condition(conditionHTMLFetched, true, "Fetched", "Fetched "+websiteURL)
+
+	if result.NotModified {
+		// The ConfigMap already has this content; rewriting it would only roll the pods
+		klog.Infof("%s not modified since last fetch, keeping ConfigMap", websiteURL)
+		fetched = condition(conditionHTMLFetched, true, "NotModified", websiteURL+" has not changed since the last fetch")
+	} else {
+		// Create or update ConfigMap with HTML content
+		if err := c.ensureConfigMap(ctx, namespace, name, result.HTML, obj.GetUID()); err != nil {
+			return c.reconcileFailed(ctx, obj, fetched, "ConfigMap", err)
+		}
 	}
 
-	// Create or update ConfigMap with HTML content
-	if err := c.ensureConfigMap(ctx, namespace, name, htmlContent, obj.GetUID()); err != nil {
-		klog.Errorf("Failed to ensure ConfigMap: %v", err)
-		return
+	// Remember the validators only once the ConfigMap holds the content they describe
+	if result.Validators != cached {
+		c.recordFetchValidators(ctx, obj, result.Validators)
 	}
 
 	// Create or update Deployment
-	if err := c.ensureDeployment(ctx, namespace, name, obj.GetUID()); err != nil {
-		klog.Errorf("Failed to ensure Deployment: %v", err)
-		return
+	if err := c.ensureDeployment(ctx, namespace, name, image, resources, obj.GetUID()); err != nil {
+		return c.reconcileFailed(ctx, obj, fetched, "Deployment", err)
 	}
 
 	// Create or update Service
 	if err := c.ensureService(ctx, namespace, name, obj.GetUID()); err != nil {
-		klog.Errorf("Failed to ensure Service: %v", err)
-		return
+		return c.reconcileFailed(ctx, obj, fetched, "Service", err)
 	}
 
 	// Create or update Ingress (optional)
 	if err := c.ensureIngress(ctx, namespace, name, obj.GetUID()); err != nil {
-		klog.Errorf("Failed to ensure Ingress: %v", err)
-		return
+		return c.reconcileFailed(ctx, obj, fetched, "Ingress", err)
+	}
+
+	// The site only counts as ready once the Deployment has a ready replica
+	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read back Deployment: %w", err)
 	}
 
-	// Update status
 	serviceURL := fmt.Sprintf("http://%s.%s.svc.cluster.local", name, namespace)
-	c.updateStatus(ctx, namespace, name, "Ready", serviceURL)
+
+	if deployment.Status.ReadyReplicas < 1 {
+		c.updateStatus(ctx, obj, "Progressing", serviceURL,
+			fetched,
+			condition(conditionDeploymentAvailable, false, "NoReadyReplicas", "Waiting for a ready replica"),
+			condition(conditionReady, false, "DeploymentProgressing", "Waiting for the Deployment to become available"),
+		)
+		return readinessRequeueInterval, nil
+	}
+
+	// Update status
+	c.updateStatus(ctx, obj, "Ready", serviceURL,
+		fetched,
+		condition(conditionDeploymentAvailable, true, "ReplicasReady",
+			fmt.Sprintf("%d of %d replicas ready", deployment.Status.ReadyReplicas, deployment.Status.Replicas)),
+		condition(conditionReady, true, "Ready", "The site is being served"),
+	)
+	return 0, nil
 }
 
-func (c *Controller) fetchHTML(url string) (string, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
+// reconcileFailed reports a failure to create or update one of the site's resources
+// and returns the result for reconcile. A resource the site doesn't own is reported
+// as a conflict and not retried.
+func (c *Controller) reconcileFailed(ctx context.Context, obj *unstructured.Unstructured, fetched metav1.Condition, resource string, err error) (time.Duration, error) {
+	if isNotOwned(err) {
+		klog.Warningf("Not updating %s for DummySite %s/%s: %v", resource, obj.GetNamespace(), obj.GetName(), err)
+		c.updateStatus(ctx, obj, "Error", "",
+			fetched,
+			condition(conditionReady, false, "Conflict", err.Error()),
+		)
+		return 0, nil
+	}
+
+	c.updateStatus(ctx, obj, "Error", "",
+		fetched,
+		condition(conditionReady, false, resource+"Failed", err.Error()),
+	)
+	return 0, fmt.Errorf("failed to ensure %s: %w", resource, err)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// condition builds a status condition; lastTransitionTime is filled in by
+// updateStatus only when the status actually changes.
+func condition(conditionType string, status bool, reason, message string) metav1.Condition {
+	c := metav1.Condition{
+		Type:    conditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
+	if status {
+		c.Status = metav1.ConditionTrue
+	}
+	return c
+}
+
+// fetchHTML downloads rawURL. When cached holds validators from an earlier fetch of
+// the same URL the request is conditional, and a 304 is reported as NotModified.
+func (c *Controller) fetchHTML(rawURL string, cached fetchValidators) (fetchResult, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
 	if err != nil {
-		return "", err
+		return fetchResult{}, err
+	}
+	if err := c.config.Fetch.checkURL(req.URL); err != nil {
+		return fetchResult{}, err
 	}
 
 	// Set headers to mimic a real browser
@@ -172,23 +490,48 @@ func (c *Controller) fetchHTML(url string) (string, error) {
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Connection", "keep-alive")
+	if cached.URL == rawURL {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
 
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return fetchResult{}, err
 	}
 	defer resp.Body.Close()
 
+	validators := fetchValidators{
+		URL:          rawURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached.URL == rawURL {
+		// A 304 may omit validators that are still current
+		if validators.ETag == "" {
+			validators.ETag = cached.ETag
+		}
+		if validators.LastModified == "" {
+			validators.LastModified = cached.LastModified
+		}
+		return fetchResult{Validators: validators, NotModified: true}, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return fetchResult{}, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return fetchResult{}, err
 	}
 
-	return string(body), nil
+	return fetchResult{HTML: string(body), Validators: validators}, nil
 }
 
 func (c *Controller) ensureConfigMap(ctx context.Context, namespace, name, content string, ownerUID types.UID) error {
@@ -211,19 +554,26 @@ func (c *Controller) ensureConfigMap(ctx context.Context, namespace, name, conte
 		},
 	}
 
-	_, err := c.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, configMap.Name, metav1.GetOptions{})
+	existing, err := c.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, configMap.Name, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		_, err = c.clientset.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{})
 		return err
 	} else if err != nil {
 		return err
 	}
+	if err := checkOwned(existing, "ConfigMap", ownerUID); err != nil {
+		return err
+	}
+	if !configMapChanged(existing, configMap) {
+		return nil
+	}
 
-	_, err = c.clientset.CoreV1().ConfigMaps(namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	existing.Data = configMap.Data
+	_, err = c.clientset.CoreV1().ConfigMaps(namespace).Update(ctx, existing, metav1.UpdateOptions{})
 	return err
 }
 
-func (c *Controller) ensureDeployment(ctx context.Context, namespace, name string, ownerUID types.UID) error {
+func (c *Controller) ensureDeployment(ctx context.Context, namespace, name, image string, resources corev1.ResourceRequirements, ownerUID types.UID) error {
 	replicas := int32(1)
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -255,8 +605,9 @@ func (c *Controller) ensureDeployment(ctx context.Context, namespace, name strin
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
-							Name:  "nginx",
-							Image: "nginx:alpine",
+							Name:      "nginx",
+							Image:     image,
+							Resources: resources,
 							Ports: []corev1.ContainerPort{
 								{
 									ContainerPort: 80,
@@ -288,15 +639,23 @@ func (c *Controller) ensureDeployment(ctx context.Context, namespace, name strin
 		},
 	}
 
-	_, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
+	existing, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		_, err = c.clientset.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
 		return err
 	} else if err != nil {
 		return err
 	}
+	if err := checkOwned(existing, "Deployment", ownerUID); err != nil {
+		return err
+	}
+	if !deploymentChanged(existing, deployment) {
+		return nil
+	}
 
-	_, err = c.clientset.AppsV1().Deployments(namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+	existing.Spec.Replicas = deployment.Spec.Replicas
+	existing.Spec.Template = deployment.Spec.Template
+	_, err = c.clientset.AppsV1().Deployments(namespace).Update(ctx, existing, metav1.UpdateOptions{})
 	return err
 }
 
@@ -330,15 +689,25 @@ func (c *Controller) ensureService(ctx context.Context, namespace, name string,
 		},
 	}
 
-	_, err := c.clientset.CoreV1().Services(namespace).Get(ctx, service.Name, metav1.GetOptions{})
+	existing, err := c.clientset.CoreV1().Services(namespace).Get(ctx, service.Name, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		_, err = c.clientset.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
 		return err
 	} else if err != nil {
 		return err
 	}
+	if err := checkOwned(existing, "Service", ownerUID); err != nil {
+		return err
+	}
+	if !serviceChanged(existing, service) {
+		return nil
+	}
 
-	_, err = c.clientset.CoreV1().Services(namespace).Update(ctx, service, metav1.UpdateOptions{})
+	// Keep the allocated cluster IP
+	existing.Spec.Type = service.Spec.Type
+	existing.Spec.Selector = service.Spec.Selector
+	existing.Spec.Ports = service.Spec.Ports
+	_, err = c.clientset.CoreV1().Services(namespace).Update(ctx, existing, metav1.UpdateOptions{})
 	return err
 }
 
@@ -385,23 +754,88 @@ func (c *Controller) ensureIngress(ctx context.Context, namespace, name string,
 		},
 	}
 
-	_, err := c.clientset.NetworkingV1().Ingresses(namespace).Get(ctx, ingress.Name, metav1.GetOptions{})
+	existing, err := c.clientset.NetworkingV1().Ingresses(namespace).Get(ctx, ingress.Name, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		_, err = c.clientset.NetworkingV1().Ingresses(namespace).Create(ctx, ingress, metav1.CreateOptions{})
 		return err
 	} else if err != nil {
 		return err
 	}
+	if err := checkOwned(existing, "Ingress", ownerUID); err != nil {
+		return err
+	}
+	if !ingressChanged(existing, ingress) {
+		return nil
+	}
 
-	_, err = c.clientset.NetworkingV1().Ingresses(namespace).Update(ctx, ingress, metav1.UpdateOptions{})
+	existing.Spec.Rules = ingress.Spec.Rules
+	_, err = c.clientset.NetworkingV1().Ingresses(namespace).Update(ctx, existing, metav1.UpdateOptions{})
 	return err
 }
 
-func (c *Controller) updateStatus(ctx context.Context, namespace, name, state, url string) {
-	statusMap := map[string]interface{}{
-		"state": state,
-		"url":   url,
+// updateStatus records the summary state and url together with the given
+// conditions. Conditions are merged into the existing ones, so lastTransitionTime
+// only moves when a condition's status flips.
+func (c *Controller) updateStatus(ctx context.Context, site *unstructured.Unstructured, state, url string, conditions ...metav1.Condition) {
+	namespace, name := site.GetNamespace(), site.GetName()
+
+	obj, err := c.dynamicClient.Resource(dummySiteGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		klog.Errorf("Failed to get DummySite for status update: %v", err)
+		return
+	}
+
+	var current []metav1.Condition
+	existing, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, item := range existing {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var cond metav1.Condition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &cond); err == nil {
+			current = append(current, cond)
+		}
+	}
+
+	for _, cond := range conditions {
+		cond.ObservedGeneration = obj.GetGeneration()
+		meta.SetStatusCondition(&current, cond)
+	}
+
+	encoded := make([]interface{}, 0, len(current))
+	for _, cond := range current {
+		m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&cond)
+		if err != nil {
+			klog.Errorf("Failed to encode condition %s: %v", cond.Type, err)
+			return
+		}
+		encoded = append(encoded, m)
+	}
+
+	// Set fields individually so others, such as the fetch validators, are kept
+	statusFields := map[string]interface{}{
+		"state":      state,
+		"url":        url,
+		"conditions": encoded,
+	}
+	for field, value := range statusFields {
+		if err := unstructured.SetNestedField(obj.Object, value, "status", field); err != nil {
+			klog.Errorf("Failed to set status: %v", err)
+			return
+		}
+	}
+
+	_, err = c.dynamicClient.Resource(dummySiteGVR).Namespace(namespace).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Errorf("Failed to update status: %v", err)
 	}
+}
+
+// recordFetchValidators stores the validators of the page now held in the ConfigMap
+// in status.fetch, so the next reconcile can fetch it conditionally.
+func (c *Controller) recordFetchValidators(ctx context.Context, site *unstructured.Unstructured, validators fetchValidators) {
+	namespace, name := site.GetNamespace(), site.GetName()
 
 	obj, err := c.dynamicClient.Resource(dummySiteGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
@@ -409,17 +843,96 @@ func (c *Controller) updateStatus(ctx context.Context, namespace, name, state, u
 		return
 	}
 
-	if err := unstructured.SetNestedMap(obj.Object, statusMap, "status"); err != nil {
-		klog.Errorf("Failed to set status: %v", err)
+	fetchStatus := map[string]interface{}{
+		"url":          validators.URL,
+		"etag":         validators.ETag,
+		"lastModified": validators.LastModified,
+	}
+	if err := unstructured.SetNestedField(obj.Object, fetchStatus, "status", "fetch"); err != nil {
+		klog.Errorf("Failed to set fetch status: %v", err)
 		return
 	}
 
 	_, err = c.dynamicClient.Resource(dummySiteGVR).Namespace(namespace).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
 	if err != nil {
-		klog.Errorf("Failed to update status: %v", err)
+		klog.Errorf("Failed to update fetch status: %v", err)
 	}
 }
 
+// quantityFromEnv parses a resource quantity from key, falling back to defaultValue.
+// A malformed value is fatal so a typo can't silently leave pods unbounded.
+func quantityFromEnv(key, defaultValue string) resource.Quantity {
+	value := os.Getenv(key)
+	if value == "" {
+		value = defaultValue
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		klog.Fatalf("Invalid %s %q: %v", key, value, err)
+	}
+	return q
+}
+
+// durationFromEnv parses a positive duration from key, falling back to defaultValue.
+func durationFromEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		klog.Fatalf("Invalid %s %q: must be a positive duration", key, value)
+	}
+	return d
+}
+
+// intFromEnv parses a non-negative integer from key, falling back to defaultValue.
+func intFromEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		klog.Fatalf("Invalid %s %q: must be a non-negative integer", key, value)
+	}
+	return n
+}
+
+// listFromEnv splits a comma-separated list from key, dropping empty entries.
+func listFromEnv(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// siteResources returns the container resources for a DummySite: the controller
+// defaults, with requests and limits each replaced by spec.resources when set.
+func (c *Controller) siteResources(spec map[string]interface{}) (corev1.ResourceRequirements, error) {
+	resources := *c.config.DefaultResources.DeepCopy()
+
+	raw, found, err := unstructured.NestedMap(spec, "resources")
+	if err != nil || !found {
+		return resources, err
+	}
+
+	var override corev1.ResourceRequirements
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &override); err != nil {
+		return resources, fmt.Errorf("invalid spec.resources: %w", err)
+	}
+	if len(override.Requests) > 0 {
+		resources.Requests = override.Requests
+	}
+	if len(override.Limits) > 0 {
+		resources.Limits = override.Limits
+	}
+	return resources, nil
+}
+
 func boolPtr(b bool) *bool {
 	return &b
 }
@@ -440,7 +953,53 @@ func main() {
 		klog.Fatalf("Failed to create dynamic client: %v", err)
 	}
 
-	controller := NewController(clientset, dynamicClient)
+	var cfg ControllerConfig
+
+	// Optionally scope the controller to one namespace and/or a label selector, so
+	// several instances can each serve their own tenant. Empty means cluster-wide.
+	cfg.Namespace = os.Getenv("WATCH_NAMESPACE")
+	cfg.LabelSelector = os.Getenv("LABEL_SELECTOR")
+	if _, err := labels.Parse(cfg.LabelSelector); err != nil {
+		klog.Fatalf("Invalid LABEL_SELECTOR %q: %v", cfg.LabelSelector, err)
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = corev1.NamespaceAll
+	}
+	klog.Infof("Watching DummySites in namespace %q with label selector %q", cfg.Namespace, cfg.LabelSelector)
+
+	// Default requests/limits for site containers, so they aren't BestEffort and can
+	// run under a ResourceQuota
+	cfg.DefaultResources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    quantityFromEnv("SITE_CPU_REQUEST", "10m"),
+			corev1.ResourceMemory: quantityFromEnv("SITE_MEMORY_REQUEST", "16Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    quantityFromEnv("SITE_CPU_LIMIT", "100m"),
+			corev1.ResourceMemory: quantityFromEnv("SITE_MEMORY_LIMIT", "64Mi"),
+		},
+	}
+
+	// Fetch hardening: private/internal addresses are blocked unless explicitly allowed
+	cfg.Fetch = FetchPolicy{
+		Timeout:              durationFromEnv("FETCH_TIMEOUT", 30*time.Second),
+		MaxRedirects:         intFromEnv("FETCH_MAX_REDIRECTS", 5),
+		AllowedHosts:         listFromEnv("FETCH_ALLOWED_HOSTS"),
+		DeniedHosts:          listFromEnv("FETCH_DENIED_HOSTS"),
+		AllowPrivateNetworks: os.Getenv("FETCH_ALLOW_PRIVATE_NETWORKS") == "true",
+	}
+
+	// A shorter resync corrects manual edits to site resources sooner, at the cost of
+	// a reconcile, with its website fetch and API reads, per DummySite each period
+	cfg.ResyncPeriod = durationFromEnv("RESYNC_PERIOD", 10*time.Minute)
+	klog.Infof("Resyncing DummySites every %s", cfg.ResyncPeriod)
+
+	cfg.Workers = intFromEnv("CONTROLLER_WORKERS", 2)
+	if cfg.Workers < 1 {
+		klog.Fatalf("Invalid CONTROLLER_WORKERS %d: must be at least 1", cfg.Workers)
+	}
+
+	controller := NewController(clientset, dynamicClient, cfg)
 
 	stopCh := make(chan struct{})
 	defer close(stopCh)
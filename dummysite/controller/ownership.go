@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// errNotOwned marks an existing resource that shares a site's name but wasn't
+// created for it. The controller leaves such resources alone rather than taking
+// them over, and retrying won't help until someone removes or renames them.
+var errNotOwned = errors.New("resource not owned by DummySite")
+
+// isNotOwned reports whether err is a resource the DummySite doesn't own.
+func isNotOwned(err error) bool {
+	return errors.Is(err, errNotOwned)
+}
+
+// checkOwned returns errNotOwned unless obj carries an owner reference to the
+// DummySite with ownerUID.
+func checkOwned(obj metav1.Object, kind string, ownerUID types.UID) error {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == ownerUID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s %s/%s already exists", errNotOwned, kind, obj.GetNamespace(), obj.GetName())
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// fallbackLanguage is served when none of the languages a client accepts has a
+// translation
+const fallbackLanguage = "en"
+
+// embeddedGreetings holds the translations used when GREETINGS_JSON is unset
+//
+//go:embed greetings.json
+var embeddedGreetings []byte
+
+// greetings holds a parsed greeting template per lowercase language tag
+type greetings map[string]*template.Template
+
+// parseGreetings parses a JSON object mapping language tags to greeting templates.
+// english, if set, replaces the English greeting (GREETING_TEMPLATE).
+func parseGreetings(data []byte, english string) (greetings, error) {
+	var texts map[string]string
+	if err := json.Unmarshal(data, &texts); err != nil {
+		return nil, err
+	}
+	if english != "" {
+		texts[fallbackLanguage] = english
+	}
+	if _, ok := texts[fallbackLanguage]; !ok {
+		return nil, fmt.Errorf("no %q greeting to fall back to", fallbackLanguage)
+	}
+
+	g := make(greetings, len(texts))
+	for tag, text := range texts {
+		tmpl, err := parseGreetingTemplate(text)
+		if err != nil {
+			return nil, fmt.Errorf("greeting %q: %w", tag, err)
+		}
+		g[strings.ToLower(tag)] = tmpl
+	}
+	return g, nil
+}
+
+// match picks the greeting for an Accept-Language header: the accepted languages are
+// tried in order of preference, each first as given ("pt-br") and then by its base
+// language ("pt"). It returns the chosen language tag with its template.
+func (g greetings) match(acceptLanguage string) (string, *template.Template) {
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tmpl, ok := g[tag]; ok {
+			return tag, tmpl
+		}
+		if base, _, found := strings.Cut(tag, "-"); found {
+			if tmpl, ok := g[base]; ok {
+				return base, tmpl
+			}
+		}
+	}
+	return fallbackLanguage, g[fallbackLanguage]
+}
+
+// parseAcceptLanguage returns the lowercase language tags of an Accept-Language
+// header, most preferred first. Tags with q=0 and the "*" wildcard are dropped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag, q})
+	}
+
+	// Stable, so equally weighted tags keep the client's order
+	slices.SortStableFunc(tags, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
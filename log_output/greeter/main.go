@@ -1,23 +1,88 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"text/template"
+	"time"
 )
 
+var (
+	startTime      = time.Now()  // when the greeter booted, for /info uptime
+	requestsServed atomic.Uint64 // greetings served by the root handler
+)
+
+// greetingData holds the fields available to GREETING_TEMPLATE
+type greetingData struct {
+	Version string
+}
+
+// parseGreetingTemplate parses text and renders it once, so that both syntax errors
+// and references to unknown fields are caught at startup rather than per request.
+func parseGreetingTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("greeting").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, greetingData{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
 func main() {
+	setupLogger()
+
 	// Get version from environment variable, default to "1" if not set
 	version := os.Getenv("VERSION")
 	if version == "" {
 		version = "1"
 	}
 
+	// Translations keyed by language tag, from GREETINGS_JSON or the embedded
+	// greetings.json; GREETING_TEMPLATE still sets the English one
+	greetingsJSON := []byte(os.Getenv("GREETINGS_JSON"))
+	if len(greetingsJSON) == 0 {
+		greetingsJSON = embeddedGreetings
+	}
+	greetings, err := parseGreetings(greetingsJSON, os.Getenv("GREETING_TEMPLATE"))
+	if err != nil {
+		slog.Error("invalid greetings", "error", err)
+		os.Exit(1)
+	}
+
 	// Create handler function
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		response := fmt.Sprintf("hello from version %s", version)
-		fmt.Fprint(w, response)
+		requestsServed.Add(1)
+		language, greeting := greetings.match(r.Header.Get("Accept-Language"))
+		w.Header().Set("Content-Language", language)
+		w.Header().Add("Vary", "Accept-Language")
+
+		var buf bytes.Buffer
+		if err := greeting.Execute(&buf, greetingData{Version: version}); err != nil {
+			slog.Error("failed to render greeting", "error", err)
+			http.Error(w, "failed to render greeting", http.StatusInternalServerError)
+			return
+		}
+		buf.WriteTo(w)
+	})
+
+	// Report version, uptime and greeting count for debugging
+	http.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"version":         version,
+			"uptime_seconds":  int64(time.Since(startTime).Seconds()),
+			"requests_served": requestsServed.Load(),
+		})
 	})
 
 	// Get port from environment variable, default to 8080
@@ -27,8 +92,32 @@ func main() {
 	}
 
 	// Start server
-	log.Printf("Server starting on port %s with version %s", port, version)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatal(err)
+	server := &http.Server{
+		Addr: ":" + port,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("server starting", "port", port, "version", version)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	slog.Info("shutting down server")
+
+	// Give outstanding requests a 30 second deadline to complete
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("server forced to shutdown", "error", err)
 	}
+
+	slog.Info("server exited")
 }
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dependency is a service whose response is included in the /status output under
+// Name. ID is a stable machine-readable name, used by READINESS_DEPENDENCIES and
+// reported by a failing readiness probe.
+type dependency struct {
+	ID   string
+	Name string
+	URL  string
+}
+
+// dependencies are the services aggregated by /status, set from DEPENDENCIES. The
+// default names keep the output the course exercises expect.
+var dependencies = []dependency{
+	{ID: "pingpong", Name: "Ping/Pongs", URL: "http://pingpong-svc:80/pings"},
+	{ID: "greeter", Name: "Greetings", URL: "http://greeter-svc:80"},
+}
+
+// readinessDependencies are the IDs of the dependencies the readiness probe checks,
+// set from READINESS_DEPENDENCIES. Only pingpong gates readiness by default.
+var readinessDependencies = []string{"pingpong"}
+
+// parseDependencies parses a comma-separated list of name=url pairs. A name may be
+// given an ID as id:name; otherwise the name is also the ID.
+func parseDependencies(value string) ([]dependency, error) {
+	var deps []dependency
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, rawURL, ok := strings.Cut(pair, "=")
+		name, rawURL = strings.TrimSpace(name), strings.TrimSpace(rawURL)
+		if !ok || name == "" || rawURL == "" {
+			return nil, fmt.Errorf("%q is not a name=url pair", pair)
+		}
+		if u, err := url.Parse(rawURL); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("%q is not a valid URL", rawURL)
+		}
+		id := name
+		if before, after, found := strings.Cut(name, ":"); found {
+			id, name = strings.TrimSpace(before), strings.TrimSpace(after)
+			if id == "" || name == "" {
+				return nil, fmt.Errorf("%q is not an id:name pair", pair)
+			}
+		}
+		deps = append(deps, dependency{ID: id, Name: name, URL: rawURL})
+	}
+	if len(deps) == 0 {
+		return nil, errors.New("no dependencies given")
+	}
+	return deps, nil
+}
+
+// selectDependencies returns the dependencies with the given IDs, in the order of
+// deps, along with any IDs that matched none of them.
+func selectDependencies(deps []dependency, ids []string) (selected []dependency, unknown []string) {
+	for _, id := range ids {
+		if !slices.ContainsFunc(deps, func(dep dependency) bool { return dep.ID == id }) {
+			unknown = append(unknown, id)
+		}
+	}
+	for _, dep := range deps {
+		if slices.Contains(ids, dep.ID) {
+			selected = append(selected, dep)
+		}
+	}
+	return selected, unknown
+}
+
+// dependencyResult is the outcome of fetching one dependency.
+type dependencyResult struct {
+	body []byte
+	err  error
+}
+
+// fetchDependencies fetches every dependency concurrently. Results are returned in
+// the order of deps.
+func fetchDependencies(ctx context.Context, deps []dependency) []dependencyResult {
+	results := make([]dependencyResult, len(deps))
+
+	var wg sync.WaitGroup
+	for i, dep := range deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := fetchDependency(ctx, http.DefaultClient, dep.Name, dep.URL)
+			results[i] = dependencyResult{body: body, err: err}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// dependencyAttempts is how many times a dependency call is tried before giving up.
+var dependencyAttempts = 3
+
+// dependencyBackoff is the wait before the first retry; it doubles on each retry.
+var dependencyBackoff = 200 * time.Millisecond
+
+// statusTimeout bounds all dependency calls made for one /status request, retries
+// included, so a struggling dependency can't hold the request open indefinitely.
+var statusTimeout = 30 * time.Second
+
+// statusCodeError reports a dependency that answered with something other than 200.
+type statusCodeError struct {
+	StatusCode int
+}
+
+func (e *statusCodeError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
+
+// fetchDependency GETs rawURL and returns the body, retrying connection failures and 5xx
+// responses with exponential backoff. Retries stop early once ctx is done.
+func fetchDependency(ctx context.Context, client *http.Client, service, rawURL string) ([]byte, error) {
+	backoff := dependencyBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var body []byte
+		body, err = getOnce(ctx, client, rawURL)
+		if err == nil {
+			return body, nil
+		}
+
+		var statusErr *statusCodeError
+		retryable := !errors.As(err, &statusErr) || statusErr.StatusCode >= 500
+		if !retryable || attempt >= dependencyAttempts {
+			return nil, err
+		}
+
+		slog.Warn("dependency call failed, retrying",
+			"service", service,
+			"attempt", attempt,
+			"backoff", backoff,
+			"error", err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func getOnce(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusCodeError{StatusCode: resp.StatusCode}
+	}
+
+	return io.ReadAll(resp.Body)
+}
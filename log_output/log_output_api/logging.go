@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogger installs the default slog logger, configured from LOG_LEVEL
+// (debug|info|warn|error, default info) and LOG_FORMAT (text|json, default text).
+func setupLogger() {
+	var level slog.Level
+	levelValue := os.Getenv("LOG_LEVEL")
+	invalidLevel := false
+	if levelValue != "" {
+		if err := level.UnmarshalText([]byte(levelValue)); err != nil {
+			level = slog.LevelInfo
+			invalidLevel = true
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+
+	if invalidLevel {
+		logger.Warn("invalid LOG_LEVEL, using info", "value", levelValue)
+	}
+	if format != "" && format != "text" && format != "json" {
+		logger.Warn("invalid LOG_FORMAT, using text", "value", format)
+	}
+}
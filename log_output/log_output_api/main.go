@@ -1,31 +1,121 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// readinessTimeout bounds the dependency checks made by each readiness probe.
+var readinessTimeout = 5 * time.Second
+
+// checkedByReadiness are the dependencies selected by READINESS_DEPENDENCIES.
+var checkedByReadiness []dependency
+
+// readinessStatus is the JSON body returned by the readiness probe.
+type readinessStatus struct {
+	Ready      bool   `json:"ready"`
+	Dependency string `json:"dependency,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
 func main() {
+	setupLogger()
+
+	if value := os.Getenv("READINESS_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			slog.Warn("invalid READINESS_TIMEOUT, using default", "value", value, "default", readinessTimeout)
+		} else {
+			readinessTimeout = timeout
+		}
+	}
+
+	if value := os.Getenv("DEPENDENCY_ATTEMPTS"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 1 {
+			slog.Warn("invalid DEPENDENCY_ATTEMPTS, using default", "value", value, "default", dependencyAttempts)
+		} else {
+			dependencyAttempts = attempts
+		}
+	}
+
+	if value := os.Getenv("DEPENDENCY_BACKOFF"); value != "" {
+		backoff, err := time.ParseDuration(value)
+		if err != nil || backoff < 0 {
+			slog.Warn("invalid DEPENDENCY_BACKOFF, using default", "value", value, "default", dependencyBackoff)
+		} else {
+			dependencyBackoff = backoff
+		}
+	}
+
+	if value := os.Getenv("STATUS_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			slog.Warn("invalid STATUS_TIMEOUT, using default", "value", value, "default", statusTimeout)
+		} else {
+			statusTimeout = timeout
+		}
+	}
+
+	if value := os.Getenv("DEPENDENCIES"); value != "" {
+		deps, err := parseDependencies(value)
+		if err != nil {
+			slog.Warn("invalid DEPENDENCIES, using default", "value", value, "error", err)
+		} else {
+			dependencies = deps
+		}
+	}
+	for _, dep := range dependencies {
+		slog.Info("status dependency", "id", dep.ID, "name", dep.Name, "url", dep.URL)
+	}
+
+	// Readiness only waits on the dependencies listed here; the rest may be down
+	// without taking this service out of rotation
+	if value, ok := os.LookupEnv("READINESS_DEPENDENCIES"); ok {
+		readinessDependencies = nil
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				readinessDependencies = append(readinessDependencies, id)
+			}
+		}
+	}
+	readinessDeps, unknown := selectDependencies(dependencies, readinessDependencies)
+	if len(unknown) > 0 && os.Getenv("READINESS_DEPENDENCIES") != "" {
+		slog.Warn("unknown READINESS_DEPENDENCIES, ignoring them", "ids", unknown)
+	}
+	checkedByReadiness = readinessDeps
+
+	// A missing file only fails readiness, as the generator or a ConfigMap mount may
+	// still provide it
+	if name, err := checkFiles(); err != nil {
+		slog.Warn("status file not readable, readiness will fail until it is", "file", name, "error", err)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 	// Generate a random UUID on startup
 	randomString := uuid.New().String()
-	fmt.Printf("Application started. Random string: %s\n", randomString)
+	slog.Info("application started", "random_string", randomString)
 	// Expose an HTTP endpoint for current status
 	http.HandleFunc("/", rootHandler)
 	http.HandleFunc("/status", statusHandler)
 	http.HandleFunc("/readiness", readinessHandler)
-	fmt.Printf("Server started on port %s\n", port)
+	slog.Info("server started", "port", port)
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		panic(err)
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
 	}
 }
 func rootHandler(w http.ResponseWriter, r *http.Request) {
@@ -35,113 +125,106 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 
 // Readiness probe endpoint
 func readinessHandler(w http.ResponseWriter, r *http.Request) {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	// Check if we can reach the pingpong service
-	resp, err := client.Get("http://pingpong-svc:80/pings")
-	if err != nil {
-		slog.Warn("readiness check failed: cannot reach pingpong service", "error", err)
-		http.Error(w, fmt.Sprintf("Pingpong service not reachable: %v", err), http.StatusServiceUnavailable)
+	// The files /status reads must be present before traffic is routed here
+	if name, err := checkFiles(); err != nil {
+		slog.Warn("readiness check failed: file not readable", "file", name, "error", err)
+		writeNotReady(w, name, fmt.Sprintf("not readable: %v", err))
 		return
 	}
-	defer resp.Body.Close()
 
-	// Check if the response is successful
-	if resp.StatusCode != http.StatusOK {
-		slog.Warn("readiness check failed: pingpong service returned non-OK status", "status", resp.StatusCode)
-		http.Error(w, fmt.Sprintf("Pingpong service not ready: status %d", resp.StatusCode), http.StatusServiceUnavailable)
-		return
-	}
+	// The dependencies in READINESS_DEPENDENCIES must answer, retries included,
+	// within the readiness timeout
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
 
-	// Verify we can read the response
-	_, err = io.ReadAll(resp.Body)
-	if err != nil {
-		slog.Warn("readiness check failed: cannot read pingpong response", "error", err)
-		http.Error(w, "Cannot read pingpong response", http.StatusServiceUnavailable)
+	for i, result := range fetchDependencies(ctx, checkedByReadiness) {
+		if result.err == nil {
+			continue
+		}
+		dep := checkedByReadiness[i]
+		slog.Warn("readiness check failed: dependency unavailable", "service", dep.ID, "url", dep.URL, "error", result.err)
+
+		var statusErr *statusCodeError
+		if errors.As(result.err, &statusErr) {
+			writeNotReady(w, dep.ID, statusErr.Error())
+		} else {
+			writeNotReady(w, dep.ID, fmt.Sprintf("not reachable: %v", result.err))
+		}
 		return
 	}
 
 	// All checks passed
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprint(w, "ready")
+	writeReadiness(w, http.StatusOK, readinessStatus{Ready: true})
 }
 
-func statusHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain")
+// writeNotReady fails the readiness probe with a 503, naming the dependency at fault
+// so the reason can be parsed from probe events and logs.
+func writeNotReady(w http.ResponseWriter, dependency, reason string) {
+	writeReadiness(w, http.StatusServiceUnavailable, readinessStatus{
+		Ready:      false,
+		Dependency: dependency,
+		Error:      reason,
+	})
+}
 
-	// --- Resolve environment paths ---
-	logPath := os.Getenv("LOG_PATH")
+func writeReadiness(w http.ResponseWriter, status int, body readinessStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("failed to write readiness response", "error", err)
+	}
+}
+
+// filePaths returns the paths of the log file and the config file read by /status
+func filePaths() (logPath, configPath string) {
+	logPath = os.Getenv("LOG_PATH")
 	if logPath == "" {
 		logPath = "../logoutput.txt"
 	}
 
-	configPath := os.Getenv("CONFIG_FILE_PATH")
+	configPath = os.Getenv("CONFIG_FILE_PATH")
 	if configPath == "" {
 		configPath = "../information.txt"
 	}
+	return logPath, configPath
+}
 
-	message := os.Getenv("MESSAGE")
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// checkFiles verifies that the files read by /status can be opened, returning the
+// name of the first one that can't
+func checkFiles() (string, error) {
+	logPath, configPath := filePaths()
+	for _, file := range []struct{ name, path string }{
+		{"log_file", logPath},
+		{"config_file", configPath},
+	} {
+		f, err := os.Open(file.path)
+		if err != nil {
+			return file.name, err
+		}
+		f.Close()
 	}
+	return "", nil
+}
 
-	// --- Call pingpong service ---
-	pingpongResp, err := client.Get("http://pingpong-svc:80/pings")
-	if err != nil {
-		slog.Error("failed to call pingpong service",
-			"error", err,
-			"service", "pingpong-svc",
-			"url", "http://pingpong-svc:80/pings",
-		)
-		http.Error(w, "failed to reach pingpong service", http.StatusBadGateway)
-		return
-	}
-	defer pingpongResp.Body.Close()
-
-	if pingpongResp.StatusCode != http.StatusOK {
-		slog.Warn("unexpected response from pingpong service",
-			"status", pingpongResp.StatusCode,
-		)
-		http.Error(w, fmt.Sprintf("unexpected status from pingpong: %d", pingpongResp.StatusCode), pingpongResp.StatusCode)
-		return
-	}
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
 
-	pingpongBody, err := io.ReadAll(pingpongResp.Body)
-	if err != nil {
-		slog.Error("failed to read pingpong response", "error", err)
-		http.Error(w, "failed to read pingpong response", http.StatusInternalServerError)
-		return
-	}
+	// --- Resolve environment paths ---
+	logPath, configPath := filePaths()
 
-	// --- Call greeter service ---
-	greeterResp, err := client.Get("http://greeter-svc:80")
-	if err != nil {
-		slog.Error("failed to call greeter service",
-			"error", err,
-			"service", "greeter-svc",
-			"url", "http://greeter-svc:80",
-		)
-		http.Error(w, "failed to reach greeter service", http.StatusBadGateway)
-		return
-	}
-	defer greeterResp.Body.Close()
+	message := os.Getenv("MESSAGE")
 
-	if greeterResp.StatusCode != http.StatusOK {
-		slog.Warn("unexpected response from greeter service",
-			"status", greeterResp.StatusCode,
-		)
-		http.Error(w, fmt.Sprintf("unexpected status from greeter: %d", greeterResp.StatusCode), greeterResp.StatusCode)
-		return
-	}
+	// --- Call dependencies ---
+	// One deadline covers every call and its retries
+	ctx, cancel := context.WithTimeout(r.Context(), statusTimeout)
+	defer cancel()
 
-	greeterBody, err := io.ReadAll(greeterResp.Body)
-	if err != nil {
-		slog.Error("failed to read greeter response", "error", err)
-		http.Error(w, "failed to read greeter response", http.StatusInternalServerError)
-		return
+	results := fetchDependencies(ctx, dependencies)
+	for i, result := range results {
+		if result.err != nil {
+			writeDependencyError(w, dependencies[i], result.err)
+			return
+		}
 	}
 
 	// --- Read log file ---
@@ -161,14 +244,37 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// --- Combine output ---
-	combined := fmt.Sprintf(
-		"Config file content: %s\nMessage (env): %s\nLog file content:\n%s\nPing/Pongs: %s\nGreetings: %s\n",
+	var combined strings.Builder
+	fmt.Fprintf(&combined,
+		"Config file content: %s\nMessage (env): %s\nLog file content:\n%s\n",
 		string(configData),
 		message,
 		string(logData),
-		string(pingpongBody),
-		string(greeterBody),
 	)
+	for i, result := range results {
+		fmt.Fprintf(&combined, "%s: %s\n", dependencies[i].Name, string(result.body))
+	}
 
-	_, _ = w.Write([]byte(combined))
+	_, _ = w.Write([]byte(combined.String()))
+}
+
+// writeDependencyError reports a failed dependency call: an unexpected status is
+// passed on, anything else is a 502.
+func writeDependencyError(w http.ResponseWriter, dep dependency, err error) {
+	var statusErr *statusCodeError
+	if errors.As(err, &statusErr) {
+		slog.Warn("unexpected response from dependency",
+			"service", dep.Name,
+			"status", statusErr.StatusCode,
+		)
+		http.Error(w, fmt.Sprintf("unexpected status from %s: %d", dep.Name, statusErr.StatusCode), statusErr.StatusCode)
+		return
+	}
+
+	slog.Error("failed to call dependency",
+		"error", err,
+		"service", dep.Name,
+		"url", dep.URL,
+	)
+	http.Error(w, fmt.Sprintf("failed to reach %s service", dep.Name), http.StatusBadGateway)
 }
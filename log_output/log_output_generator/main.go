@@ -1,45 +1,139 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// lastWrite holds the time of the last successful status write, in Unix nanoseconds
+var lastWrite atomic.Int64
+
 func main() {
+	setupLogger()
+
 	filePath := os.Getenv("FILE_PATH")
 	if filePath == "" {
 		filePath = "../logoutput.txt"
 	}
 
+	interval := 5 * time.Second
+	if value := os.Getenv("STATUS_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			slog.Warn("invalid STATUS_INTERVAL, using default", "value", value, "default", interval)
+		} else {
+			interval = d
+		}
+	}
+
+	// The generator shares a pod with log_output_api, so it needs its own port
+	healthPort := os.Getenv("HEALTH_PORT")
+	if healthPort == "" {
+		healthPort = "8081"
+	}
+
 	randomString := uuid.New().String()
-	fmt.Printf("Application started. Random string: %s\n", randomString)
+	slog.Info("application started", "random_string", randomString, "interval", interval)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthzHandler(interval))
+	server := &http.Server{
+		Addr:    ":" + healthPort,
+		Handler: mux,
+	}
+
+	go func() {
+		slog.Info("health server started", "port", healthPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("health server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
-		currentStatus := fmt.Sprintf(
-			"%s : %s\n",
-			time.Now().UTC().Format(time.RFC3339Nano),
-			randomString,
-		)
-
-		// Open file in truncate mode
-		f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-		if err != nil {
-			fmt.Println("Error opening file:", err)
-			return
+		if err := writeStatus(filePath, randomString); err != nil {
+			slog.Error("failed to write status", "path", filePath, "error", err)
 		}
 
-		_, err = f.WriteString(currentStatus)
-		if err != nil {
-			fmt.Println("Error writing to file:", err)
-			f.Close()
+		select {
+		case <-ctx.Done():
+			slog.Info("shutting down")
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				slog.Error("health server forced to shutdown", "error", err)
+			}
 			return
+		case <-ticker.C:
 		}
+	}
+}
+
+// writeStatus overwrites filePath with the current timestamp and random string
+func writeStatus(filePath, randomString string) error {
+	currentStatus := fmt.Sprintf(
+		"%s : %s\n",
+		time.Now().UTC().Format(time.RFC3339Nano),
+		randomString,
+	)
+
+	// Open file in truncate mode
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+
+	if _, err := f.WriteString(currentStatus); err != nil {
 		f.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close file: %w", err)
+	}
+
+	lastWrite.Store(time.Now().UnixNano())
+	slog.Info("wrote status", "status", strings.TrimSuffix(currentStatus, "\n"))
+	return nil
+}
+
+// healthzHandler reports healthy while status writes keep succeeding, i.e. the last
+// one happened within two intervals
+func healthzHandler(interval time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		last := time.Unix(0, lastWrite.Load())
+		if lastWrite.Load() == 0 || time.Since(last) > 2*interval {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"status": "unhealthy",
+				"error":  "no recent status write",
+			})
+			return
+		}
 
-		fmt.Print("Wrote: ", currentStatus) // optional console log
-		time.Sleep(5 * time.Second)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":     "healthy",
+			"last_write": last.UTC().Format(time.RFC3339Nano),
+		})
 	}
 }
@@ -1,18 +1,33 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var counter uint64
 var db *sql.DB
 
+// maxHistoryLimit caps the limit accepted by /pings/history
+var maxHistoryLimit = 100
+
+// defaultHistoryLimit is used when /pings/history is called without a limit
+const defaultHistoryLimit = 20
+
 func initDB() {
 	connStr := os.Getenv("DATABASE_URL")
 	var err error
@@ -26,6 +41,17 @@ func initDB() {
             id SERIAL PRIMARY KEY,
             value BIGINT NOT NULL
         );
+    `)
+	if err != nil {
+		panic(err)
+	}
+	// Log of individual pings, for /pings/history
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS pings_log (
+            id SERIAL PRIMARY KEY,
+            counted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        );
+        CREATE INDEX IF NOT EXISTS pings_log_counted_at_idx ON pings_log (counted_at DESC);
     `)
 	if err != nil {
 		panic(err)
@@ -49,20 +75,141 @@ func initDB() {
 	}
 }
 func handlePingPong(w http.ResponseWriter, r *http.Request) {
-	// increment atomically
-	newCount := atomic.AddUint64(&counter, 1)
-	// persist to DB
-	_, err := db.Exec("UPDATE counter SET value = $1 WHERE id = 1", newCount)
+	pingRequests.Inc()
+	// The database is the source of truth: increment there, logging the ping
+	// alongside the new total, and only reflect the result in memory once committed
+	tx, err := db.Begin()
 	if err != nil {
-		http.Error(w, "DB update failed", http.StatusInternalServerError)
+		dbUpdateFailed(w, err)
 		return
 	}
+	defer tx.Rollback()
+	var newCount uint64
+	if err := tx.QueryRow("UPDATE counter SET value = value + 1 WHERE id = 1 RETURNING value").Scan(&newCount); err != nil {
+		dbUpdateFailed(w, err)
+		return
+	}
+	if _, err := tx.Exec("INSERT INTO pings_log (counted_at) VALUES (now())"); err != nil {
+		dbUpdateFailed(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		dbUpdateFailed(w, err)
+		return
+	}
+	storeCounter(newCount)
 	fmt.Fprintf(w, "pong %d", newCount)
 }
+
+// storeCounter updates the in-memory counter to a committed value. Concurrent pings
+// can commit in one order and get here in another, so it never moves backwards.
+func storeCounter(value uint64) {
+	for {
+		current := atomic.LoadUint64(&counter)
+		if value <= current || atomic.CompareAndSwapUint64(&counter, current, value) {
+			return
+		}
+	}
+}
+
+// dbUpdateFailed records and reports a ping that couldn't be persisted
+func dbUpdateFailed(w http.ResponseWriter, err error) {
+	dbUpdateFailures.Inc()
+	log.Printf("DB update failed: %v", err)
+	http.Error(w, "DB update failed", http.StatusInternalServerError)
+}
+
 func handlePings(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "%d", atomic.LoadUint64(&counter))
 }
 
+// handlePingsHistory returns the timestamps of the most recent pings, newest first
+func handlePingsHistory(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	limit = min(limit, maxHistoryLimit)
+
+	rows, err := db.Query("SELECT counted_at FROM pings_log ORDER BY counted_at DESC LIMIT $1", limit)
+	if err != nil {
+		http.Error(w, "DB query failed", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	history := []time.Time{}
+	for rows.Next() {
+		var countedAt time.Time
+		if err := rows.Scan(&countedAt); err != nil {
+			http.Error(w, "DB query failed", http.StatusInternalServerError)
+			return
+		}
+		history = append(history, countedAt.UTC())
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "DB query failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// probeTimeout bounds the database checks made by /healthz and /readyz
+const probeTimeout = 2 * time.Second
+
+// writeProbeStatus writes a JSON probe response; err is reported when non-nil
+func writeProbeStatus(w http.ResponseWriter, status int, state string, err error) {
+	body := map[string]string{"status": state}
+	if err != nil {
+		body["error"] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleHealthz reports whether the database is reachable
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		writeProbeStatus(w, http.StatusServiceUnavailable, "unhealthy", err)
+		return
+	}
+	writeProbeStatus(w, http.StatusOK, "healthy", nil)
+}
+
+// handleReadyz additionally checks that the counter row pings are stored in exists
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		writeProbeStatus(w, http.StatusServiceUnavailable, "not ready", err)
+		return
+	}
+
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM counter WHERE id = 1)").Scan(&exists)
+	if err != nil {
+		writeProbeStatus(w, http.StatusServiceUnavailable, "not ready", err)
+		return
+	}
+	if !exists {
+		writeProbeStatus(w, http.StatusServiceUnavailable, "not ready", errors.New("counter row missing"))
+		return
+	}
+	writeProbeStatus(w, http.StatusOK, "ready", nil)
+}
+
 // Readiness probe endpoint
 func handleReadiness(w http.ResponseWriter, r *http.Request) {
 	// Check if database connection is alive
@@ -88,12 +235,53 @@ func main() {
 	if port == "" {
 		port = "8080"
 	}
+	if value := os.Getenv("PINGS_HISTORY_MAX_LIMIT"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			fmt.Printf("Invalid PINGS_HISTORY_MAX_LIMIT %q, using %d\n", value, maxHistoryLimit)
+		} else {
+			maxHistoryLimit = n
+		}
+	}
 	initDB()
 	http.HandleFunc("/", handlePingPong)
 	http.HandleFunc("/pings", handlePings)
+	http.HandleFunc("/pings/history", handlePingsHistory)
 	http.HandleFunc("/readiness", handleReadiness)
-	fmt.Printf("Server started on port %s\n", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		panic(err)
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/readyz", handleReadyz)
+	http.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr: ":" + port,
 	}
+
+	// Start server in a goroutine
+	go func() {
+		fmt.Printf("Server started on port %s\n", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	fmt.Println("Shutting down server...")
+
+	// Give outstanding requests a 30 second deadline to complete
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("Server forced to shutdown: %v\n", err)
+	}
+
+	// Close the database only once in-flight requests are done with it
+	if err := db.Close(); err != nil {
+		fmt.Printf("Error closing database: %v\n", err)
+	}
+
+	fmt.Println("Server exited")
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPingPongDBFailure(t *testing.T) {
+	// A closed handle fails every query without needing a database
+	closed, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	previous := db
+	db = closed
+	t.Cleanup(func() { db = previous })
+
+	atomic.StoreUint64(&counter, 41)
+	failuresBefore := testutil.ToFloat64(dbUpdateFailures)
+
+	rec := httptest.NewRecorder()
+	handlePingPong(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := atomic.LoadUint64(&counter); got != 41 {
+		t.Errorf("counter = %d, want it unchanged at 41", got)
+	}
+	if got := testutil.ToFloat64(dbUpdateFailures) - failuresBefore; got != 1 {
+		t.Errorf("dbUpdateFailures increased by %v, want 1", got)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics, served at /metrics
+var (
+	pingRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pingpong_requests_total",
+		Help: "Number of requests to the ping-pong endpoint.",
+	})
+
+	dbUpdateFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pingpong_db_update_failures_total",
+		Help: "Number of pings that could not be persisted to the database.",
+	})
+
+	// The gauge reads the in-memory counter, so it shows the value /pings returns
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "pingpong_counter_value",
+		Help: "Current value of the ping-pong counter.",
+	}, func() float64 {
+		return float64(atomic.LoadUint64(&counter))
+	})
+)
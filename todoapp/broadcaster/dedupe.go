@@ -0,0 +1,55 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// SentCache remembers the stream sequences of the most recently sent messages, so a
+// message redelivered after its Telegram send succeeded (but its ack was lost) isn't
+// posted twice. The stream sequence is used rather than the consumer sequence, which
+// changes on every redelivery. Being in memory, it doesn't survive a restart.
+type SentCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recent at the front
+	seqs     map[uint64]*list.Element
+}
+
+func NewSentCache(capacity int) *SentCache {
+	return &SentCache{
+		capacity: capacity,
+		order:    list.New(),
+		seqs:     make(map[uint64]*list.Element, capacity),
+	}
+}
+
+// Seen reports whether the message with stream sequence seq was already sent
+func (c *SentCache) Seen(seq uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.seqs[seq]
+	if ok {
+		c.order.MoveToFront(el)
+	}
+	return ok
+}
+
+// Add records seq as sent, evicting the least recently used sequence when full
+func (c *SentCache) Add(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.seqs[seq]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.seqs[seq] = c.order.PushFront(seq)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.seqs, oldest.Value.(uint64))
+	}
+}
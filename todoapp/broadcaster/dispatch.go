@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// maxDigestItems caps the todos listed in a digest, keeping it well under Telegram's
+// 4096 character message limit
+const maxDigestItems = 20
+
+// pendingEvent is a todo event waiting in a batch, with the message to ack once the
+// batch has been sent
+type pendingEvent struct {
+	todo      TodoMessage
+	msg       *nats.Msg
+	streamSeq uint64
+	requestID string
+}
+
+// Dispatcher sends todo events to Telegram. With a BatchWindow, events are buffered
+// per action and sent as a single digest when the window closes; otherwise each
+// event is sent on its own. A message is only acked once its Telegram message has
+// been sent, so batched events still in the buffer at shutdown are redelivered.
+type Dispatcher struct {
+	config   Config
+	telegram *TelegramClient
+	sent     *SentCache
+
+	mu      sync.Mutex
+	batches map[string][]pendingEvent
+}
+
+func NewDispatcher(config Config, telegram *TelegramClient, sent *SentCache) *Dispatcher {
+	return &Dispatcher{
+		config:   config,
+		telegram: telegram,
+		sent:     sent,
+		batches:  make(map[string][]pendingEvent),
+	}
+}
+
+// Dispatch sends ev straight away, or adds it to the batch for its action
+func (d *Dispatcher) Dispatch(ev pendingEvent) {
+	if d.config.BatchWindow <= 0 {
+		d.sendAndAck([]pendingEvent{ev}, formatTodoMessage(ev.todo))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	action := ev.todo.Action
+	if len(d.batches[action]) == 0 {
+		time.AfterFunc(d.config.BatchWindow, func() { d.flush(action) })
+	}
+	d.batches[action] = append(d.batches[action], ev)
+}
+
+// flush sends the batch collected for action
+func (d *Dispatcher) flush(action string) {
+	d.mu.Lock()
+	events := d.batches[action]
+	delete(d.batches, action)
+	d.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	message := formatTodoMessage(events[0].todo)
+	if len(events) > 1 {
+		message = formatDigest(action, events)
+	}
+	d.sendAndAck(events, message)
+}
+
+// sendAndAck sends message on behalf of events, then acks them, or naks them all so
+// they are redelivered if sending failed
+func (d *Dispatcher) sendAndAck(events []pendingEvent, message string) {
+	requestIDs := make([]string, len(events))
+	for i, ev := range events {
+		requestIDs[i] = ev.requestID
+	}
+	requests := strings.Join(requestIDs, ", ")
+
+	if err := d.deliver(message, requests); err != nil {
+		log.Printf("Error sending to Telegram (request %s): %v", requests, err)
+		for _, ev := range events {
+			ev.msg.Nak()
+		}
+		return
+	}
+
+	for _, ev := range events {
+		if ev.streamSeq != 0 {
+			d.sent.Add(ev.streamSeq)
+		}
+		ev.msg.Ack()
+	}
+}
+
+// deliver sends message to Telegram, or only logs it in dry-run mode and on staging
+func (d *Dispatcher) deliver(message, requests string) error {
+	switch {
+	case d.config.DryRun:
+		log.Printf("[dry-run] Telegram message not sent:\n%s", message)
+	case d.config.Environment == "staging":
+		log.Print(message)
+	default:
+		if err := d.telegram.SendMessage(message); err != nil {
+			return err
+		}
+		log.Printf("Successfully sent message to Telegram (request %s)", requests)
+	}
+	return nil
+}
+
+// formatDigest summarises several events of the same action in one message, e.g.
+// "5 todos completed"
+func formatDigest(action string, events []pendingEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 *%d todos %s*\n", len(events), escapeMarkdown(action))
+
+	for i, ev := range events {
+		if i == maxDigestItems {
+			fmt.Fprintf(&b, "\n…and %d more", len(events)-maxDigestItems)
+			break
+		}
+		fmt.Fprintf(&b, "\n• %s (ID %d)", escapeMarkdown(ev.todo.Title), ev.todo.ID)
+	}
+
+	return b.String()
+}
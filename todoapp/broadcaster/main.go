@@ -9,33 +9,80 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"streamconfig"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // This version uses NATS JetStream for guaranteed message delivery
 // and durable consumers for exactly-once processing semantics
 
 type TodoMessage struct {
-	Action      string `json:"action"`
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
+	Action      string     `json:"action"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Priority    int        `json:"priority"`
+	// Count is the number of todos affected by a "cleared" summary event
+	Count int `json:"count,omitempty"`
 }
 
 type Config struct {
 	NatsURL       string
 	TelegramToken string
 	TelegramChat  string
-	Subject       string
 	HealthPort    string
-	StreamName    string
 	ConsumerName  string
 	Environment   string
+	DryRun        bool
+	// Stream is the TODOS stream, defined the same way as in the backend
+	Stream streamconfig.Settings
+	// ForwardActions limits which todo actions are sent to Telegram; nil forwards all
+	ForwardActions map[string]bool
+	Debug          bool
+	// AckWait is how long JetStream waits for an ack before redelivering a message,
+	// at most MaxDeliver times
+	AckWait    time.Duration
+	MaxDeliver int
+	// DedupeCacheSize is how many recently sent messages are remembered to skip
+	// redeliveries of
+	DedupeCacheSize int
+	// BatchWindow is how long events are collected into a digest; 0 sends each
+	// event on its own
+	BatchWindow time.Duration
+	// NatsOptions set up authentication and TLS for the NATS connection
+	NatsOptions []nats.Option
+	// TelegramTimeout bounds each send; TelegramMaxIdleConns connections are kept
+	// open between sends
+	TelegramTimeout      time.Duration
+	TelegramMaxIdleConns int
+}
+
+// minAckWait is the shortest AckWait accepted: twice the time a Telegram send may
+// take, so a slow send isn't redelivered (and duplicated) while still in progress.
+// Raise it if SendMessage starts retrying.
+func (c Config) minAckWait() time.Duration {
+	return 2 * c.TelegramTimeout
+}
+
+// shouldForward reports whether events with the given action are sent on
+func (c Config) shouldForward(action string) bool {
+	return c.ForwardActions == nil || c.ForwardActions[action]
+}
+
+// debugf logs only when LOG_LEVEL=debug
+func (c Config) debugf(format string, args ...interface{}) {
+	if c.Debug {
+		log.Printf("[debug] "+format, args...)
+	}
 }
 
 type HealthChecker struct {
@@ -91,31 +138,89 @@ func (h *HealthChecker) GetLastNatsCheckTime() time.Time {
 
 func main() {
 	config := Config{
-		NatsURL:       getEnv("NATS_URL", "nats://localhost:4222"),
-		TelegramToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
-		TelegramChat:  getEnv("TELEGRAM_CHAT_ID", ""),
-		Subject:       getEnv("NATS_SUBJECT", "todos.events"),
-		HealthPort:    getEnv("PORT", "4000"),
-		StreamName:    getEnv("STREAM_NAME", "TODOS"),
-		ConsumerName:  getEnv("CONSUMER_NAME", "broadcaster"),
-		Environment:   getEnv("ENVIRONMENT", "Prod"),
+		NatsURL:         getEnv("NATS_URL", "nats://localhost:4222"),
+		TelegramToken:   getEnv("TELEGRAM_BOT_TOKEN", ""),
+		TelegramChat:    getEnv("TELEGRAM_CHAT_ID", ""),
+		HealthPort:      getEnv("PORT", "4000"),
+		Stream:          streamconfig.FromEnv(),
+		ConsumerName:    getEnv("CONSUMER_NAME", "broadcaster"),
+		Environment:     getEnv("ENVIRONMENT", "Prod"),
+		DryRun:          getEnvBool("DRY_RUN", false),
+		ForwardActions:  parseActions(getEnv("FORWARD_ACTIONS", "")),
+		Debug:           strings.EqualFold(getEnv("LOG_LEVEL", "info"), "debug"),
+		AckWait:         getEnvDuration("ACK_WAIT", 30*time.Second),
+		MaxDeliver:      getEnvInt("MAX_DELIVER", 3),
+		DedupeCacheSize: getEnvInt("DEDUPE_CACHE_SIZE", 1024),
+		BatchWindow:     getEnvDuration("BATCH_WINDOW", 0),
+
+		TelegramTimeout:      getEnvDuration("TELEGRAM_TIMEOUT", defaultTelegramTimeout),
+		TelegramMaxIdleConns: getEnvInt("TELEGRAM_MAX_IDLE_CONNS", defaultTelegramMaxIdleConns),
+	}
+
+	natsOptions, err := streamconfig.ConnectOptionsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid NATS connection settings: %v", err)
+	}
+	config.NatsOptions = natsOptions
+
+	if config.TelegramTimeout <= 0 {
+		log.Printf("Invalid TELEGRAM_TIMEOUT=%s, using %s", config.TelegramTimeout, defaultTelegramTimeout)
+		config.TelegramTimeout = defaultTelegramTimeout
+	}
+	if config.TelegramMaxIdleConns < 1 {
+		log.Printf("Invalid TELEGRAM_MAX_IDLE_CONNS=%d, using %d", config.TelegramMaxIdleConns, defaultTelegramMaxIdleConns)
+		config.TelegramMaxIdleConns = defaultTelegramMaxIdleConns
+	}
+	if minAckWait := config.minAckWait(); config.AckWait < minAckWait {
+		log.Printf("ACK_WAIT=%s is below the Telegram send budget, using %s", config.AckWait, minAckWait)
+		config.AckWait = minAckWait
+	}
+	if config.MaxDeliver < 1 && config.MaxDeliver != -1 {
+		log.Printf("Invalid MAX_DELIVER=%d (must be positive, or -1 for unlimited), using 3", config.MaxDeliver)
+		config.MaxDeliver = 3
+	}
+	if config.DedupeCacheSize < 1 {
+		log.Printf("Invalid DEDUPE_CACHE_SIZE=%d, using 1024", config.DedupeCacheSize)
+		config.DedupeCacheSize = 1024
+	}
+	// A batched message waits for the window and then the send, all within AckWait
+	if maxWindow := config.AckWait - config.minAckWait(); config.BatchWindow > maxWindow {
+		log.Printf("BATCH_WINDOW=%s leaves too little of ACK_WAIT=%s for sending, using %s", config.BatchWindow, config.AckWait, maxWindow)
+		config.BatchWindow = maxWindow
+	}
+	if config.BatchWindow > 0 {
+		log.Printf("Batching todo events into digests every %s", config.BatchWindow)
 	}
 
-	if config.TelegramToken == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN environment variable is required")
+	if config.ForwardActions != nil {
+		log.Printf("Forwarding only todo actions: %s", getEnv("FORWARD_ACTIONS", ""))
 	}
 
-	if config.TelegramChat == "" {
-		log.Fatal("TELEGRAM_CHAT_ID environment variable is required")
+	// In dry-run mode nothing is sent, so the service can boot without credentials
+	if config.DryRun {
+		log.Println("DRY_RUN enabled: messages will be logged and acked, not sent to Telegram")
+	} else {
+		if config.TelegramToken == "" {
+			log.Fatal("TELEGRAM_BOT_TOKEN environment variable is required")
+		}
+
+		if config.TelegramChat == "" {
+			log.Fatal("TELEGRAM_CHAT_ID environment variable is required")
+		}
 	}
 
 	healthChecker := &HealthChecker{}
+	consumerStats := NewConsumerStatsCache(config.Stream.Name, config.ConsumerName)
 
 	// Start health check server
-	healthServer := startHealthServer(config.HealthPort, healthChecker)
+	healthServer := startHealthServer(config.HealthPort, healthChecker, consumerStats)
 
 	// Create Telegram client
-	telegram := NewTelegramClient(config.TelegramToken, config.TelegramChat)
+	telegram := NewTelegramClient(config.TelegramToken, config.TelegramChat, config.TelegramTimeout, config.TelegramMaxIdleConns)
+
+	// Shared across reconnects so redeliveries after a reconnect are caught too
+	sent := NewSentCache(config.DedupeCacheSize)
+	dispatcher := NewDispatcher(config, telegram, sent)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -124,16 +229,17 @@ func main() {
 	var nc *nats.Conn
 	var js nats.JetStreamContext
 	var sub *nats.Subscription
-	var err error
 
 	// Initial connection
-	nc, js, sub, err = connectAndSubscribeJetStream(config, telegram, healthChecker)
+	nc, js, sub, err = connectAndSubscribeJetStream(config, dispatcher, healthChecker)
 	if err != nil {
 		log.Printf("Initial connection failed: %v. Will retry...", err)
+	} else {
+		consumerStats.SetJetStream(js)
 	}
 
 	// Monitor connection
-	go monitorConnectionJetStream(ctx, &nc, &js, &sub, config, telegram, healthChecker)
+	go monitorConnectionJetStream(ctx, &nc, &js, &sub, config, dispatcher, healthChecker, consumerStats)
 
 	log.Println("Broadcaster service is running with JetStream. Press Ctrl+C to exit.")
 
@@ -163,12 +269,11 @@ func main() {
 	log.Println("Broadcaster service stopped")
 }
 
-func connectAndSubscribeJetStream(config Config, telegram *TelegramClient, healthChecker *HealthChecker) (*nats.Conn, nats.JetStreamContext, *nats.Subscription, error) {
+func connectAndSubscribeJetStream(config Config, dispatcher *Dispatcher, healthChecker *HealthChecker) (*nats.Conn, nats.JetStreamContext, *nats.Subscription, error) {
 	// Connect to NATS
-	nc, err := nats.Connect(
-		config.NatsURL,
+	opts := []nats.Option{
 		nats.MaxReconnects(-1),
-		nats.ReconnectWait(2*time.Second),
+		nats.ReconnectWait(2 * time.Second),
 		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
 			if err != nil {
 				log.Printf("NATS disconnected: %v", err)
@@ -179,13 +284,15 @@ func connectAndSubscribeJetStream(config Config, telegram *TelegramClient, healt
 		nats.ReconnectHandler(func(nc *nats.Conn) {
 			log.Printf("NATS reconnected to %s", nc.ConnectedUrl())
 			healthChecker.SetNatsConnected(true)
+			natsReconnects.Inc()
 		}),
 		nats.ClosedHandler(func(nc *nats.Conn) {
 			log.Println("NATS connection closed")
 			healthChecker.SetNatsConnected(false)
 			healthChecker.SetReady(false)
 		}),
-	)
+	}
+	nc, err := nats.Connect(config.NatsURL, append(opts, config.NatsOptions...)...)
 	if err != nil {
 		healthChecker.SetNatsConnected(false)
 		healthChecker.SetReady(false)
@@ -204,36 +311,32 @@ func connectAndSubscribeJetStream(config Config, telegram *TelegramClient, healt
 	}
 
 	// Ensure stream exists
-	streamConfig := &nats.StreamConfig{
-		Name:     config.StreamName,
-		Subjects: []string{config.Subject},
-		Storage:  nats.FileStorage,
-		MaxAge:   24 * time.Hour,
-		Replicas: 1,
-	}
-
-	stream, err := js.StreamInfo(config.StreamName)
-	if err != nil {
-		_, err = js.AddStream(streamConfig)
-		if err != nil {
-			nc.Close()
-			return nil, nil, nil, fmt.Errorf("failed to create stream: %w", err)
-		}
-		log.Printf("Created JetStream stream: %s", config.StreamName)
-	} else {
-		log.Printf("Using existing JetStream stream: %s (messages: %d)", config.StreamName, stream.State.Msgs)
+	if err := streamconfig.Ensure(js, config.Stream); err != nil {
+		nc.Close()
+		return nil, nil, nil, err
 	}
 
 	// Check if consumer exists and delete if incompatible
-	consumerInfo, err := js.ConsumerInfo(config.StreamName, config.ConsumerName)
+	consumerInfo, err := js.ConsumerInfo(config.Stream.Name, config.ConsumerName)
 	if err == nil {
 		// Consumer exists - check if it's pull-based or missing deliver group
 		if consumerInfo.Config.DeliverSubject == "" || consumerInfo.Config.DeliverGroup == "" {
 			log.Printf("Deleting incompatible consumer: %s", config.ConsumerName)
-			if err := js.DeleteConsumer(config.StreamName, config.ConsumerName); err != nil {
+			if err := js.DeleteConsumer(config.Stream.Name, config.ConsumerName); err != nil {
 				nc.Close()
 				return nil, nil, nil, fmt.Errorf("failed to delete consumer: %w", err)
 			}
+		} else if consumerInfo.Config.AckWait != config.AckWait || consumerInfo.Config.MaxDeliver != config.MaxDeliver {
+			// Keep the deliver subject other replicas are bound to; only the
+			// redelivery settings change
+			updated := consumerInfo.Config
+			updated.AckWait = config.AckWait
+			updated.MaxDeliver = config.MaxDeliver
+			if _, err := js.UpdateConsumer(config.Stream.Name, &updated); err != nil {
+				nc.Close()
+				return nil, nil, nil, fmt.Errorf("failed to update consumer: %w", err)
+			}
+			log.Printf("Updated consumer %s: AckWait=%s, MaxDeliver=%d", config.ConsumerName, config.AckWait, config.MaxDeliver)
 		}
 	}
 
@@ -242,13 +345,13 @@ func connectAndSubscribeJetStream(config Config, telegram *TelegramClient, healt
 		Durable:        config.ConsumerName,
 		DeliverPolicy:  nats.DeliverAllPolicy,
 		AckPolicy:      nats.AckExplicitPolicy,
-		MaxDeliver:     3,
-		AckWait:        30 * time.Second,
+		MaxDeliver:     config.MaxDeliver,
+		AckWait:        config.AckWait,
 		DeliverSubject: nats.NewInbox(),
 		DeliverGroup:   "broadcaster-workers",
 	}
 
-	_, err = js.AddConsumer(config.StreamName, consumerConfig)
+	_, err = js.AddConsumer(config.Stream.Name, consumerConfig)
 	if err != nil && !errors.Is(err, nats.ErrConsumerNameAlreadyInUse) {
 		nc.Close()
 		return nil, nil, nil, fmt.Errorf("failed to create consumer: %w", err)
@@ -256,7 +359,7 @@ func connectAndSubscribeJetStream(config Config, telegram *TelegramClient, healt
 
 	// Subscribe using QueueSubscribe (Push mode with load balancing)
 	sub, err := js.QueueSubscribe(
-		config.Subject,
+		config.Stream.Subject,
 		"broadcaster-workers", // Must match DeliverGroup
 		func(msg *nats.Msg) {
 			healthChecker.UpdateLastMessage()
@@ -268,39 +371,51 @@ func connectAndSubscribeJetStream(config Config, telegram *TelegramClient, healt
 				return
 			}
 
-			log.Printf("Processing todo event: %s - ID: %d", todoMsg.Action, todoMsg.ID)
-			message := formatTodoMessage(todoMsg)
+			// Filtered-out actions are acked so they aren't redelivered
+			if !config.shouldForward(todoMsg.Action) {
+				config.debugf("Skipping todo event %s - ID: %d (not in FORWARD_ACTIONS)", todoMsg.Action, todoMsg.ID)
+				msg.Ack()
+				return
+			}
 
-			if config.Environment == "staging" {
-				log.Print(message)
+			// Set by the backend to the ID of the API call that produced the event
+			requestID := msg.Header.Get("X-Request-ID")
 
-			} else {
-				if err := telegram.SendMessage(message); err != nil {
-					log.Printf("Error sending to Telegram: %v", err)
-					msg.Nak()
+			// A redelivery of a message that was sent but not acked is only acked again
+			var streamSeq uint64
+			if meta, err := msg.Metadata(); err == nil {
+				streamSeq = meta.Sequence.Stream
+				if meta.NumDelivered > 1 && dispatcher.sent.Seen(streamSeq) {
+					log.Printf("Skipping redelivered todo event %s - ID: %d (stream seq %d already sent)", todoMsg.Action, todoMsg.ID, streamSeq)
+					msg.Ack()
 					return
 				}
-				log.Printf("Successfully sent message to Telegram")
 			}
-			msg.Ack()
 
+			log.Printf("Processing todo event: %s - ID: %d (request %s)", todoMsg.Action, todoMsg.ID, requestID)
+			dispatcher.Dispatch(pendingEvent{
+				todo:      todoMsg,
+				msg:       msg,
+				streamSeq: streamSeq,
+				requestID: requestID,
+			})
 		},
 		nats.Durable(config.ConsumerName),
 		nats.ManualAck(),
-		nats.Bind(config.StreamName, config.ConsumerName),
+		nats.Bind(config.Stream.Name, config.ConsumerName),
 	)
 	if err != nil {
 		nc.Close()
 		return nil, nil, nil, fmt.Errorf("failed to subscribe: %w", err)
 	}
 
-	log.Printf("Subscribed to subject: %s with durable PUSH consumer: %s", config.Subject, config.ConsumerName)
+	log.Printf("Subscribed to subject: %s with durable PUSH consumer: %s", config.Stream.Subject, config.ConsumerName)
 	healthChecker.SetReady(true)
 
 	return nc, js, sub, nil
 }
 
-func monitorConnectionJetStream(ctx context.Context, nc **nats.Conn, js *nats.JetStreamContext, sub **nats.Subscription, config Config, telegram *TelegramClient, healthChecker *HealthChecker) {
+func monitorConnectionJetStream(ctx context.Context, nc **nats.Conn, js *nats.JetStreamContext, sub **nats.Subscription, config Config, dispatcher *Dispatcher, healthChecker *HealthChecker, consumerStats *ConsumerStatsCache) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
@@ -321,7 +436,7 @@ func monitorConnectionJetStream(ctx context.Context, nc **nats.Conn, js *nats.Je
 					(*nc).Drain()
 				}
 
-				newNc, newJs, newSub, err := connectAndSubscribeJetStream(config, telegram, healthChecker)
+				newNc, newJs, newSub, err := connectAndSubscribeJetStream(config, dispatcher, healthChecker)
 				if err != nil {
 					log.Printf("Reconnection failed: %v", err)
 					continue
@@ -330,6 +445,8 @@ func monitorConnectionJetStream(ctx context.Context, nc **nats.Conn, js *nats.Je
 				*nc = newNc
 				*js = newJs
 				*sub = newSub
+				consumerStats.SetJetStream(newJs)
+				natsReconnects.Inc()
 				log.Println("Successfully reconnected to NATS with JetStream")
 			} else {
 				healthChecker.SetNatsConnected(true)
@@ -338,9 +455,12 @@ func monitorConnectionJetStream(ctx context.Context, nc **nats.Conn, js *nats.Je
 	}
 }
 
-func startHealthServer(port string, healthChecker *HealthChecker) *http.Server {
+func startHealthServer(port string, healthChecker *HealthChecker, consumerStats *ConsumerStatsCache) *http.Server {
 	mux := http.NewServeMux()
 
+	registerConnectedGauge(healthChecker)
+	mux.Handle("/metrics", promhttp.Handler())
+
 	mux.HandleFunc("/liveness", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -402,6 +522,24 @@ func startHealthServer(port string, healthChecker *HealthChecker) *http.Server {
 		json.NewEncoder(w).Encode(status)
 	})
 
+	// Consumer lag, for alerting when the Telegram notifier falls behind
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		stats, err := consumerStats.Get()
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": err.Error(),
+				"time":  time.Now().Format(time.RFC3339),
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(stats)
+	})
+
 	server := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
@@ -418,10 +556,21 @@ func startHealthServer(port string, healthChecker *HealthChecker) *http.Server {
 }
 
 func formatTodoMessage(todo TodoMessage) string {
+	// A summary of completed todos being cleared in one go has no single todo to show
+	if todo.Action == "cleared" {
+		return fmt.Sprintf("🧹 *Completed Todos Cleared*\n\n*Count:* %d", todo.Count)
+	}
+
 	var status string
 	switch todo.Action {
 	case "created":
 		status = "📝 *New Todo Created*"
+	case "completed":
+		status = "✅ *Todo Completed*"
+	case "deleted":
+		status = "🗑️ *Todo Deleted*"
+	case "restored":
+		status = "♻️ *Todo Restored*"
 	case "updated":
 		if todo.Completed {
 			status = "✅ *Todo Completed*"
@@ -436,17 +585,51 @@ func formatTodoMessage(todo TodoMessage) string {
 		"*Title:* %s\n"+
 		"*Description:* %s\n"+
 		"*Status:* %s\n"+
+		"*Priority:* %s\n"+
 		"*ID:* %d",
 		status,
 		escapeMarkdown(todo.Title),
 		escapeMarkdown(todo.Description),
 		getStatusEmoji(todo.Completed),
+		getPriorityLabel(todo.Priority),
 		todo.ID,
 	)
 
+	if todo.DueDate != nil {
+		message += fmt.Sprintf("\n*Due:* %s", formatDueIn(*todo.DueDate, time.Now()))
+	}
+
 	return message
 }
 
+// formatDueIn renders a due date relative to now, e.g. "in 2 days" or "overdue by 3 hours"
+func formatDueIn(due, now time.Time) string {
+	d := due.Sub(now)
+	overdue := d < 0
+	if overdue {
+		d = -d
+	}
+
+	var amount string
+	switch {
+	case d >= 48*time.Hour:
+		amount = fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= 24*time.Hour:
+		amount = "1 day"
+	case d >= 2*time.Hour:
+		amount = fmt.Sprintf("%d hours", int(d.Hours()))
+	case d >= time.Hour:
+		amount = "1 hour"
+	default:
+		amount = "less than an hour"
+	}
+
+	if overdue {
+		return "overdue by " + amount
+	}
+	return "in " + amount
+}
+
 func getStatusEmoji(completed bool) string {
 	if completed {
 		return "Completed ✅"
@@ -454,6 +637,17 @@ func getStatusEmoji(completed bool) string {
 	return "Pending ⏳"
 }
 
+func getPriorityLabel(priority int) string {
+	switch priority {
+	case 2:
+		return "High 🔴"
+	case 1:
+		return "Medium 🟡"
+	default:
+		return "Low 🟢"
+	}
+}
+
 func escapeMarkdown(text string) string {
 	replacer := map[rune]string{
 		'_': "\\_", '*': "\\*", '[': "\\[", ']': "\\]",
@@ -480,3 +674,62 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// parseActions turns a comma-separated action list into a set, or nil when empty
+func parseActions(value string) map[string]bool {
+	var actions map[string]bool
+	for _, action := range strings.Split(value, ",") {
+		action = strings.ToLower(strings.TrimSpace(action))
+		if action == "" {
+			continue
+		}
+		if actions == nil {
+			actions = make(map[string]bool)
+		}
+		actions[action] = true
+	}
+	return actions
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getEnvInt reads an integer from the environment, falling back to defaultValue when
+// it is unset or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvDuration reads a duration such as "45s" from the environment, falling back
+// to defaultValue when it is unset or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics, served on the health port at /metrics
+var natsReconnects = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "broadcaster_nats_reconnects_total",
+	Help: "Number of successful reconnections to NATS, by the client or the connection monitor.",
+})
+
+// registerConnectedGauge exposes healthChecker's view of the NATS connection as
+// broadcaster_nats_connected (1 connected, 0 not)
+func registerConnectedGauge(healthChecker *HealthChecker) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "broadcaster_nats_connected",
+		Help: "Whether the broadcaster is connected to NATS.",
+	}, func() float64 {
+		if healthChecker.IsNatsConnected() {
+			return 1
+		}
+		return 0
+	})
+}
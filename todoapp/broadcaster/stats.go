@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// consumerStatsTTL is how long a ConsumerInfo result is served from cache, so that
+// frequent scrapes of /stats don't hammer the NATS server.
+const consumerStatsTTL = 2 * time.Second
+
+// ConsumerStats reports how far the broadcaster's durable consumer is behind the stream
+type ConsumerStats struct {
+	NumPending     uint64 `json:"num_pending"`
+	NumAckPending  int    `json:"num_ack_pending"`
+	NumRedelivered int    `json:"num_redelivered"`
+	Delivered      struct {
+		ConsumerSeq uint64 `json:"consumer_seq"`
+	} `json:"delivered"`
+}
+
+// ConsumerStatsCache fetches and caches ConsumerInfo for the broadcaster's consumer
+type ConsumerStatsCache struct {
+	mu           sync.Mutex
+	js           nats.JetStreamContext
+	streamName   string
+	consumerName string
+	stats        *ConsumerStats
+	fetchedAt    time.Time
+}
+
+func NewConsumerStatsCache(streamName, consumerName string) *ConsumerStatsCache {
+	return &ConsumerStatsCache{
+		streamName:   streamName,
+		consumerName: consumerName,
+	}
+}
+
+// SetJetStream swaps in the JetStream context of a new connection
+func (c *ConsumerStatsCache) SetJetStream(js nats.JetStreamContext) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.js = js
+	c.stats = nil
+}
+
+// Get returns the cached stats, refreshing them once they are older than consumerStatsTTL
+func (c *ConsumerStatsCache) Get() (*ConsumerStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stats != nil && time.Since(c.fetchedAt) < consumerStatsTTL {
+		return c.stats, nil
+	}
+
+	if c.js == nil {
+		return nil, errors.New("not connected to JetStream")
+	}
+
+	info, err := c.js.ConsumerInfo(c.streamName, c.consumerName)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ConsumerStats{
+		NumPending:     info.NumPending,
+		NumAckPending:  info.NumAckPending,
+		NumRedelivered: info.NumRedelivered,
+	}
+	stats.Delivered.ConsumerSeq = info.Delivered.Consumer
+
+	c.stats = stats
+	c.fetchedAt = time.Now()
+
+	return stats, nil
+}
@@ -4,10 +4,23 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 )
 
+// defaultTelegramTimeout bounds a single sendMessage call unless TELEGRAM_TIMEOUT
+// says otherwise. SendMessage makes one attempt, so the timeout is also the longest
+// a message can spend in the handler; the consumer's AckWait must stay well above
+// it (see minAckWait in main.go).
+const defaultTelegramTimeout = 10 * time.Second
+
+// defaultTelegramMaxIdleConns is how many idle connections to the Telegram API are
+// kept for reuse unless TELEGRAM_MAX_IDLE_CONNS says otherwise. The default
+// transport keeps only two per host, too few once digests and retries send in
+// bursts.
+const defaultTelegramMaxIdleConns = 8
+
 type TelegramClient struct {
 	token  string
 	chatID string
@@ -25,12 +38,19 @@ type TelegramResponse struct {
 	Description string `json:"description,omitempty"`
 }
 
-func NewTelegramClient(token, chatID string) *TelegramClient {
+// NewTelegramClient creates a client whose sends each take at most timeout, keeping
+// up to maxIdleConns connections to the API open between sends.
+func NewTelegramClient(token, chatID string, timeout time.Duration, maxIdleConns int) *TelegramClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConns
+
 	return &TelegramClient{
 		token:  token,
 		chatID: chatID,
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   timeout,
+			Transport: transport,
 		},
 	}
 }
@@ -53,7 +73,11 @@ func (t *TelegramClient) SendMessage(text string) error {
 	if err != nil {
 		return fmt.Errorf("failed to send telegram request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer func() {
+		// Read any remainder so the connection can be reused
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
 
 	var telegramResp TelegramResponse
 	if err := json.NewDecoder(resp.Body).Decode(&telegramResp); err != nil {
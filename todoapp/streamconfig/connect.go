@@ -0,0 +1,92 @@
+package streamconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ConnectOptionsFromEnv returns the nats.Connect options both services need to reach
+// a secured NATS server. The connection authenticates with NATS_CREDS (the path of
+// a credentials file), NATS_TOKEN, or NATS_USER and NATS_PASSWORD; setting more than
+// one of them is an error, as is a credentials file that can't be read. With none
+// set the connection is anonymous. TLS is configured by tlsOptionsFromEnv.
+func ConnectOptionsFromEnv() ([]nats.Option, error) {
+	opts, err := tlsOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := os.Getenv("NATS_CREDS")
+	token := os.Getenv("NATS_TOKEN")
+	user, password := os.Getenv("NATS_USER"), os.Getenv("NATS_PASSWORD")
+
+	methods := 0
+	for _, set := range []bool{creds != "", token != "", user != "" || password != ""} {
+		if set {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return nil, errors.New("set only one of NATS_CREDS, NATS_TOKEN and NATS_USER/NATS_PASSWORD")
+	}
+
+	switch {
+	case creds != "":
+		// nats.UserCredentials only reads the file when connecting; check it now so
+		// a bad mount fails at startup rather than on every reconnect
+		if _, err := os.ReadFile(creds); err != nil {
+			return nil, fmt.Errorf("NATS_CREDS: %w", err)
+		}
+		opts = append(opts, nats.UserCredentials(creds))
+		log.Printf("NATS authentication: credentials file %s", creds)
+	case token != "":
+		opts = append(opts, nats.Token(token))
+		log.Printf("NATS authentication: token")
+	case user != "":
+		opts = append(opts, nats.UserInfo(user, password))
+		log.Printf("NATS authentication: user %s", user)
+	case password != "":
+		return nil, errors.New("NATS_PASSWORD is set without NATS_USER")
+	}
+	return opts, nil
+}
+
+// tlsOptionsFromEnv returns the TLS options for the connection: NATS_TLS_CA verifies
+// the server against the given CA bundle, and NATS_TLS_CERT with NATS_TLS_KEY present
+// a client certificate for mutual TLS. The files are loaded here so an unreadable or
+// mismatched one fails at startup with an error naming it.
+func tlsOptionsFromEnv() ([]nats.Option, error) {
+	ca := os.Getenv("NATS_TLS_CA")
+	cert, key := os.Getenv("NATS_TLS_CERT"), os.Getenv("NATS_TLS_KEY")
+
+	var opts []nats.Option
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("NATS_TLS_CA: %w", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("NATS_TLS_CA: no PEM certificates in %s", ca)
+		}
+		opts = append(opts, nats.RootCAs(ca))
+		log.Printf("NATS TLS: verifying the server with CA %s", ca)
+	}
+
+	if (cert == "") != (key == "") {
+		return nil, errors.New("NATS_TLS_CERT and NATS_TLS_KEY must be set together")
+	}
+	if cert != "" {
+		if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+			return nil, fmt.Errorf("NATS_TLS_CERT/NATS_TLS_KEY: %w", err)
+		}
+		opts = append(opts, nats.ClientCert(cert, key))
+		log.Printf("NATS TLS: client certificate %s", cert)
+	}
+	return opts, nil
+}
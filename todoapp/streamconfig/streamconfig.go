@@ -0,0 +1,154 @@
+// Package streamconfig defines the TODOS JetStream stream shared by the todo backend
+// and the broadcaster. Either service may be the one to create the stream, so both
+// take its settings from here to make sure it is created the same way. Both also
+// take the options for connecting to NATS from here.
+package streamconfig
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Settings are the tunable parts of the stream configuration.
+type Settings struct {
+	Name      string
+	Subject   string
+	MaxAge    time.Duration
+	Replicas  int
+	Retention nats.RetentionPolicy
+}
+
+// retentionPolicies maps STREAM_RETENTION values to JetStream retention policies.
+var retentionPolicies = map[string]nats.RetentionPolicy{
+	"work_queue": nats.WorkQueuePolicy,
+	"limits":     nats.LimitsPolicy,
+	"interest":   nats.InterestPolicy,
+}
+
+// FromEnv reads STREAM_NAME, NATS_SUBJECT, STREAM_MAX_AGE, STREAM_REPLICAS and
+// STREAM_RETENTION. Invalid values are logged and replaced by the defaults: a TODOS
+// stream on todos.events keeping messages for 24h on one replica, removed once
+// acknowledged (work_queue).
+func FromEnv() Settings {
+	s := Settings{
+		Name:      "TODOS",
+		Subject:   "todos.events",
+		MaxAge:    24 * time.Hour,
+		Replicas:  1,
+		Retention: nats.WorkQueuePolicy,
+	}
+
+	if value := os.Getenv("STREAM_NAME"); value != "" {
+		s.Name = value
+	}
+	// The subject is published to as well as bound to the stream, so it can't be a
+	// wildcard
+	if value := os.Getenv("NATS_SUBJECT"); value != "" {
+		if strings.ContainsAny(value, "*> \t") {
+			log.Printf("Invalid NATS_SUBJECT=%q (must not contain wildcards or spaces), using default %s", value, s.Subject)
+		} else {
+			s.Subject = value
+		}
+	}
+	if value := os.Getenv("STREAM_MAX_AGE"); value != "" {
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			log.Printf("Invalid STREAM_MAX_AGE=%q, using default %s", value, s.MaxAge)
+		} else {
+			s.MaxAge = d
+		}
+	}
+	if value := os.Getenv("STREAM_REPLICAS"); value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n < 1 {
+			log.Printf("Invalid STREAM_REPLICAS=%q, using default %d", value, s.Replicas)
+		} else {
+			s.Replicas = n
+		}
+	}
+	if value := os.Getenv("STREAM_RETENTION"); value != "" {
+		if policy, ok := retentionPolicies[value]; !ok {
+			log.Printf("Invalid STREAM_RETENTION=%q (want work_queue, limits or interest), using default %s", value, s.Retention)
+		} else {
+			s.Retention = policy
+		}
+	}
+
+	return s
+}
+
+// StreamConfig returns the configuration the stream is created with.
+func (s Settings) StreamConfig() *nats.StreamConfig {
+	return &nats.StreamConfig{
+		Name:      s.Name,
+		Subjects:  []string{s.Subject},
+		Storage:   nats.FileStorage,
+		MaxAge:    s.MaxAge,
+		Retention: s.Retention,
+		Replicas:  s.Replicas,
+	}
+}
+
+// Ensure creates the stream if it doesn't exist yet. An existing stream whose
+// retention or subjects differ from s is an error, as the services would silently
+// lose or miss events on it; differences in the other settings are only logged as
+// warnings, since they are best changed deliberately.
+func Ensure(js nats.JetStreamContext, s Settings) error {
+	want := s.StreamConfig()
+
+	stream, err := js.StreamInfo(s.Name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		if _, err := js.AddStream(want); err != nil {
+			return fmt.Errorf("failed to create stream: %w", err)
+		}
+		log.Printf("Created JetStream stream: %s (subject=%s max_age=%s replicas=%d retention=%s)",
+			s.Name, s.Subject, s.MaxAge, s.Replicas, s.Retention)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up stream %s: %w", s.Name, err)
+	}
+
+	if conflicts := Conflicts(stream.Config, *want); len(conflicts) > 0 {
+		return fmt.Errorf("existing stream %s is incompatible: %s", s.Name, strings.Join(conflicts, "; "))
+	}
+
+	log.Printf("Using existing JetStream stream: %s (messages: %d)", s.Name, stream.State.Msgs)
+	for _, mismatch := range Mismatches(stream.Config, *want) {
+		log.Printf("Warning: stream %s %s", s.Name, mismatch)
+	}
+	return nil
+}
+
+// Conflicts describes each setting in which existing differs from want that the
+// services can't work with: retention, which can't be changed in place, and
+// subjects.
+func Conflicts(existing, want nats.StreamConfig) []string {
+	var conflicts []string
+	if existing.Retention != want.Retention {
+		conflicts = append(conflicts, fmt.Sprintf("has retention=%s, configured %s", existing.Retention, want.Retention))
+	}
+	if !slices.Equal(existing.Subjects, want.Subjects) {
+		conflicts = append(conflicts, fmt.Sprintf("has subjects=%v, configured %v", existing.Subjects, want.Subjects))
+	}
+	return conflicts
+}
+
+// Mismatches describes each of the remaining settings in which existing differs
+// from want.
+func Mismatches(existing, want nats.StreamConfig) []string {
+	var mismatches []string
+	if existing.MaxAge != want.MaxAge {
+		mismatches = append(mismatches, fmt.Sprintf("has max_age=%s, configured %s", existing.MaxAge, want.MaxAge))
+	}
+	if existing.Replicas != want.Replicas {
+		mismatches = append(mismatches, fmt.Sprintf("has replicas=%d, configured %d", existing.Replicas, want.Replicas))
+	}
+	return mismatches
+}
@@ -0,0 +1,34 @@
+package streamconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestConflictsAndMismatches(t *testing.T) {
+	want := nats.StreamConfig{
+		Subjects:  []string{"todos.>"},
+		Retention: nats.LimitsPolicy,
+		MaxAge:    time.Hour,
+		Replicas:  1,
+	}
+
+	tunable := want
+	tunable.MaxAge = 2 * time.Hour
+	tunable.Replicas = 3
+	if conflicts := Conflicts(tunable, want); len(conflicts) != 0 {
+		t.Errorf("max_age and replicas differences reported as conflicts: %v", conflicts)
+	}
+	if mismatches := Mismatches(tunable, want); len(mismatches) != 2 {
+		t.Errorf("mismatches = %v, want max_age and replicas", mismatches)
+	}
+
+	incompatible := want
+	incompatible.Retention = nats.WorkQueuePolicy
+	incompatible.Subjects = []string{"other.>"}
+	if conflicts := Conflicts(incompatible, want); len(conflicts) != 2 {
+		t.Errorf("conflicts = %v, want retention and subjects", conflicts)
+	}
+}
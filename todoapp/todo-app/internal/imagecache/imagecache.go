@@ -0,0 +1,365 @@
+// Package imagecache keeps a periodically refreshed random image on disk and serves it.
+package imagecache
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default dimensions of the images requested from picsum.
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 600
+)
+
+// MaxDimension bounds the width and height that can be requested from picsum.
+const MaxDimension = 5000
+
+// DefaultSourceURL is the upstream the images are fetched from.
+var DefaultSourceURL = PicsumURL(DefaultWidth, DefaultHeight)
+
+// PicsumURL returns the picsum URL for a random image of the given size.
+func PicsumURL(width, height int) string {
+	return fmt.Sprintf("https://picsum.photos/%d/%d", width, height)
+}
+
+// imageExtensions maps the image content types the cache accepts to the file
+// extension they are stored with.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// placeholderImage is served while no real image is available, so the page always
+// has something to show.
+//
+//go:embed placeholder.jpg
+var placeholderImage []byte
+
+// refreshInterval is how long an image is served before a new one is fetched.
+const refreshInterval = 10 * time.Minute
+
+// DefaultRetention is how long old image files are kept on disk. Two refresh
+// intervals keeps the previous image around while clients may still be loading it.
+const DefaultRetention = 2 * refreshInterval
+
+// ImageCache holds the currently cached image and the state needed to rotate it.
+type ImageCache struct {
+	dir       string
+	sourceURL string
+	client    *http.Client
+	retention time.Duration
+
+	refreshMu  sync.Mutex  // serializes upstream fetches
+	refreshing atomic.Bool // a background refresh is in progress
+
+	mu           sync.RWMutex // protect access to image metadata
+	path         string       // path to cached image
+	contentType  string       // detected content type of cached image
+	timestamp    time.Time    // last time image was updated
+	serveOldOnce bool         // allow serving old image one more time
+}
+
+// New creates an ImageCache storing images fetched from sourceURL in dir. Old image
+// files are removed once they are older than retention; a non-positive retention
+// uses DefaultRetention and an empty sourceURL uses DefaultSourceURL.
+func New(dir, sourceURL string, retention time.Duration) *ImageCache {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	if sourceURL == "" {
+		sourceURL = DefaultSourceURL
+	}
+
+	return &ImageCache{
+		dir:       dir,
+		sourceURL: sourceURL,
+		retention: retention,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Current returns the path of the cached image and when it was fetched. The path is
+// empty if no image has been cached yet.
+func (c *ImageCache) Current() (string, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.path, c.timestamp
+}
+
+// ServeHTTP serves the current cached image, refreshing it once it is stale.
+func (c *ImageCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	currentImagePath := c.path
+	currentImageTimestamp := c.timestamp
+	currentContentType := c.contentType
+	currentServeOldOnce := c.serveOldOnce
+	c.mu.Unlock()
+
+	now := time.Now()
+	needsUpdate := now.Sub(currentImageTimestamp) > refreshInterval
+
+	if needsUpdate {
+		if currentServeOldOnce {
+			// Fetch new image in background to avoid blocking the request
+			c.RefreshInBackground()
+		} else {
+			// Allow serving old one more time
+			c.mu.Lock()
+			c.serveOldOnce = true
+			c.mu.Unlock()
+		}
+	}
+
+	// Check if image file exists before serving
+	if !imageExists(currentImagePath) {
+		// Try to fetch a new image if none exists or the current one is missing
+		if err := c.ensureImage(); err != nil {
+			log.Printf("No image available, serving placeholder: %v", err)
+			servePlaceholder(w)
+			return
+		}
+		c.mu.RLock()
+		currentImagePath = c.path
+		currentContentType = c.contentType
+		c.mu.RUnlock()
+	}
+
+	// The image rotates, so browsers must revalidate it on every load
+	w.Header().Set("Content-Type", currentContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	http.ServeFile(w, r, currentImagePath)
+}
+
+// servePlaceholder writes the bundled placeholder image. It must not be cached, so
+// the browser picks up the real image as soon as one has been fetched.
+func servePlaceholder(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(placeholderImage)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(placeholderImage)
+}
+
+// Restore adopts the newest image already in the cache directory, e.g. one left by a
+// previous run on a persistent volume, using its modification time as the time it
+// was fetched. It reports whether an image was adopted and, if so, whether it is
+// still fresh enough to serve without a refresh.
+func (c *ImageCache) Restore() (restored, fresh bool) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return false, false
+	}
+
+	var newestPath, newestType string
+	var newestTime time.Time
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "pic_") || !isImageFile(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().After(newestTime) {
+			continue
+		}
+		newestPath = filepath.Join(c.dir, name)
+		newestType = contentTypeOf(name)
+		newestTime = info.ModTime()
+	}
+
+	if newestPath == "" {
+		return false, false
+	}
+
+	c.mu.Lock()
+	c.path = newestPath
+	c.contentType = newestType
+	c.timestamp = newestTime
+	c.serveOldOnce = false
+	c.mu.Unlock()
+
+	return true, time.Since(newestTime) <= refreshInterval
+}
+
+// Refresh downloads a new image into the cache directory and makes it current. Only
+// one fetch runs at a time; concurrent callers wait for the one in progress.
+func (c *ImageCache) Refresh() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	return c.fetch()
+}
+
+// RefreshInBackground starts a refresh unless one is already running, in which case
+// the caller simply keeps serving the stale image.
+func (c *ImageCache) RefreshInBackground() {
+	if !c.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer c.refreshing.Store(false)
+		if err := c.refreshIfStale(); err != nil {
+			log.Printf("Error fetching new image: %v", err)
+		}
+	}()
+}
+
+// refreshIfStale fetches a new image unless the current one is still fresh. A
+// request that saw the stale image just before another refresh finished would
+// otherwise fetch a second time.
+func (c *ImageCache) refreshIfStale() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if path, timestamp := c.Current(); imageExists(path) && time.Since(timestamp) <= refreshInterval {
+		return nil
+	}
+	return c.fetch()
+}
+
+// ensureImage fetches an image only if there still isn't one once any in-flight
+// refresh has finished, so a burst of requests on an empty cache fetches just once.
+func (c *ImageCache) ensureImage() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if path, _ := c.Current(); imageExists(path) {
+		return nil
+	}
+	return c.fetch()
+}
+
+// imageExists reports whether path names a file on disk.
+func imageExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// fetch downloads a new image; callers must hold refreshMu.
+func (c *ImageCache) fetch() error {
+	resp, err := c.client.Get(c.sourceURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// Sniff the payload rather than trusting the upstream's Content-Type header, so an
+	// HTML error page served with a 200 is never cached as an image
+	head := make([]byte, 512)
+	n, err := io.ReadFull(resp.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return fmt.Errorf("upstream returned non-image content type %q", contentType)
+	}
+
+	// Clean up old images to prevent disk space issues
+	c.cleanupOldImages()
+
+	// Save to cache dir with timestamp
+	filename := filepath.Join(c.dir, fmt.Sprintf("pic_%d%s", time.Now().Unix(), ext))
+	out, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, io.MultiReader(bytes.NewReader(head), resp.Body))
+	if err != nil {
+		os.Remove(filename) // Clean up partial file on error
+		return fmt.Errorf("failed to save image: %w", err)
+	}
+
+	// The previous image stays on disk until cleanupOldImages removes it, as clients
+	// that were sent the old page may still be loading it
+	c.mu.Lock()
+	c.path = filename
+	c.contentType = contentType
+	c.timestamp = time.Now()
+	c.serveOldOnce = false
+	c.mu.Unlock()
+
+	return nil
+}
+
+// isImageFile reports whether name has one of the extensions the cache writes.
+func isImageFile(name string) bool {
+	ext := filepath.Ext(name)
+	for _, imageExt := range imageExtensions {
+		if ext == imageExt {
+			return true
+		}
+	}
+	return false
+}
+
+// contentTypeOf returns the content type of an image file written by the cache.
+func contentTypeOf(name string) string {
+	ext := filepath.Ext(name)
+	for contentType, imageExt := range imageExtensions {
+		if ext == imageExt {
+			return contentType
+		}
+	}
+	return ""
+}
+
+// cleanupOldImages removes cached images older than the retention to prevent disk
+// space issues. Only the cache's own pic_ files are touched, as the directory is also
+// served at /static/, and the current image is kept however old it is.
+func (c *ImageCache) cleanupOldImages() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	currentPath, _ := c.Current()
+	now := time.Now()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "pic_") || !isImageFile(name) {
+			continue
+		}
+		if filepath.Join(c.dir, name) == currentPath {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		// Remove files older than the retention period
+		if now.Sub(info.ModTime()) > c.retention {
+			os.Remove(filepath.Join(c.dir, name))
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package imagecache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeImage creates an image file in dir last modified age ago.
+func writeImage(t *testing.T, dir, name string, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, placeholderImage, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConcurrentStaleRequestsFetchOnce(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		// Keep the fetch in flight while the other requests arrive
+		time.Sleep(50 * time.Millisecond)
+		w.Write(placeholderImage)
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	writeImage(t, dir, "pic_1.jpg", 2*refreshInterval)
+
+	c := New(dir, upstream.URL, 0)
+	if restored, fresh := c.Restore(); !restored || fresh {
+		t.Fatalf("Restore() = %v, %v; want a stale image", restored, fresh)
+	}
+
+	const requests = 50
+	var wg sync.WaitGroup
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/image", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.refreshing.Load() || hits.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := hits.Load(); got != 1 {
+		t.Errorf("upstream fetched %d times, want 1", got)
+	}
+	if _, timestamp := c.Current(); time.Since(timestamp) > refreshInterval {
+		t.Error("image was not refreshed")
+	}
+}
+
+func TestCleanupOldImages(t *testing.T) {
+	dir := t.TempDir()
+	const retention = time.Hour
+	c := New(dir, "", retention)
+
+	current := writeImage(t, dir, "pic_1.jpg", 3*retention)
+	c.mu.Lock()
+	c.path = current
+	c.mu.Unlock()
+
+	files := map[string]struct {
+		age     time.Duration
+		removed bool
+	}{
+		"pic_2.png": {2 * retention, true},
+		"pic_3.gif": {retention + time.Minute, true},
+		"pic_4.jpg": {retention / 2, false},
+		// Not the cache's own files, as the directory is also served at /static/
+		"logo.png":  {2 * retention, false},
+		"pic_5.txt": {2 * retention, false},
+	}
+	for name, f := range files {
+		writeImage(t, dir, name, f.age)
+	}
+
+	c.cleanupOldImages()
+
+	for name, f := range files {
+		if exists := imageExists(filepath.Join(dir, name)); exists == f.removed {
+			t.Errorf("%s: exists = %v, want removed = %v", name, exists, f.removed)
+		}
+	}
+	if !imageExists(current) {
+		t.Error("the current image was removed")
+	}
+}
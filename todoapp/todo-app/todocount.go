@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// todoCountTimeout bounds the stats call made while rendering the page, which is
+// served without the count rather than held up by a slow backend
+const todoCountTimeout = time.Second
+
+// statsURL is the backend's GET /todos/stats, called from this server; "" when no
+// backend address reachable from here is configured
+var statsURL string
+
+var statsClient = &http.Client{Timeout: todoCountTimeout}
+
+// fetchTodoCount asks the backend how many todos there are, so the page can show
+// the count without waiting for the browser to load the list.
+func fetchTodoCount(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, todoCountTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, statsURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := statsClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var stats struct {
+		Total *int `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return 0, err
+	}
+	if stats.Total == nil {
+		return 0, fmt.Errorf("response has no total")
+	}
+	return *stats.Total, nil
+}
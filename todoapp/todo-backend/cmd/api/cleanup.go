@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// runCleanupWorker periodically purges todos that were completed more than
+// cleanup.completedRetention ago and todos soft-deleted more than
+// cleanup.deletedRetention ago, until the application shuts down. A zero retention
+// skips that purge.
+func (app *application) runCleanupWorker() {
+	ticker := time.NewTicker(app.config.cleanup.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.done:
+			return
+		case <-ticker.C:
+		}
+
+		if retention := app.config.cleanup.completedRetention; retention > 0 {
+			app.purge("completed", retention, app.store.PurgeCompleted)
+		}
+		if retention := app.config.cleanup.deletedRetention; retention > 0 {
+			app.purge("deleted", retention, app.store.PurgeDeleted)
+		}
+	}
+}
+
+// purge runs one purge query and logs how many todos it removed.
+func (app *application) purge(kind string, retention time.Duration, fn func(context.Context, time.Duration) (int64, error)) {
+	purged, err := fn(context.Background(), retention)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"component": "cleanup"})
+		return
+	}
+
+	app.logger.PrintInfo("purged "+kind+" todos", map[string]string{
+		"component": "cleanup",
+		"purged":    strconv.FormatInt(purged, 10),
+		"retention": retention.String(),
+	})
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"streamconfig"
+	"strings"
+	"time"
+	"todo-backend/internal/data"
+
+	"github.com/nats-io/nats.go"
+)
+
+// config holds the settings read from the environment at startup.
+type config struct {
+	port string
+
+	server struct {
+		readTimeout       time.Duration
+		readHeaderTimeout time.Duration
+		writeTimeout      time.Duration
+		idleTimeout       time.Duration
+	}
+	db struct {
+		dsn             string
+		maxOpenConns    int
+		maxIdleConns    int
+		connMaxLifetime time.Duration
+	}
+	readiness struct {
+		checkNATS bool
+	}
+	nats struct {
+		required       bool
+		connectRetries int
+		connectBackoff time.Duration
+		publishTimeout time.Duration
+		asyncPublish   bool
+		maxPending     int
+		drainTimeout   time.Duration
+		// connectOptions set up authentication and TLS, from streamconfig
+		connectOptions []nats.Option
+	}
+	outbox struct {
+		pollInterval time.Duration
+	}
+	limiter struct {
+		enabled           bool
+		rps               float64
+		burst             int
+		trustForwardedFor bool
+	}
+	cleanup struct {
+		completedRetention time.Duration
+		deletedRetention   time.Duration
+		interval           time.Duration
+	}
+	cors struct {
+		maxAge int
+	}
+	todos struct {
+		maxDescriptionLength int
+	}
+	bulk struct {
+		maxItems int
+	}
+	csvImport struct {
+		maxRows  int
+		maxBytes int64
+	}
+	quota struct {
+		maxTodosPerOwner int
+	}
+	jwt struct {
+		secret string
+	}
+	docs struct {
+		enabled bool
+	}
+	gzip struct {
+		enabled bool
+	}
+	pprof struct {
+		enabled bool
+		addr    string
+	}
+	seed struct {
+		sampleTodos bool
+	}
+	clearCompleted struct {
+		summaryEvent bool
+	}
+	stream streamconfig.Settings
+}
+
+func loadConfig() config {
+	var cfg config
+
+	// Without a default ListenAndServe(":") would bind a random port.
+	cfg.port = getEnv("PORT", "8080")
+
+	// Bound how long a client may take to send a request and read the response, so
+	// slow or hung connections can't pile up. Streaming endpoints (SSE, WebSocket,
+	// export) manage their own deadlines.
+	cfg.server.readTimeout = getEnvDuration("READ_TIMEOUT", 15*time.Second)
+	cfg.server.readHeaderTimeout = getEnvDuration("READ_HEADER_TIMEOUT", 5*time.Second)
+	cfg.server.writeTimeout = getEnvDuration("WRITE_TIMEOUT", 30*time.Second)
+	cfg.server.idleTimeout = getEnvDuration("IDLE_TIMEOUT", 60*time.Second)
+
+	cfg.db.dsn = os.Getenv("DATABASE_URL")
+	// Managed Postgres plans often allow only a few dozen connections in total, so
+	// keep the pool bounded rather than letting database/sql open them on demand.
+	cfg.db.maxOpenConns = getEnvInt("DB_MAX_OPEN_CONNS", 25)
+	cfg.db.maxIdleConns = getEnvInt("DB_MAX_IDLE_CONNS", 25)
+	cfg.db.connMaxLifetime = getEnvDuration("DB_CONN_MAX_LIFETIME", 15*time.Minute)
+
+	// Deployments without the broadcaster can opt out of failing readiness on NATS.
+	cfg.readiness.checkNATS = getEnvBool("READINESS_CHECK_NATS", true)
+
+	// NATS may be made optional so the backend still serves todos during a NATS
+	// outage at startup; events are then not published until it is restarted.
+	cfg.nats.required = getEnvBool("NATS_REQUIRED", true)
+	cfg.nats.connectRetries = getEnvInt("NATS_CONNECT_RETRIES", 5)
+	cfg.nats.connectBackoff = getEnvDuration("NATS_CONNECT_BACKOFF", 2*time.Second)
+
+	// How long a synchronous publish waits for JetStream's ack.
+	cfg.nats.publishTimeout = getEnvDuration("NATS_PUBLISH_TIMEOUT", 5*time.Second)
+	if cfg.nats.publishTimeout <= 0 {
+		cfg.nats.publishTimeout = 5 * time.Second
+	}
+
+	// Async publishing sends each outbox batch before waiting for its acks, instead
+	// of waiting for every event's ack in turn. Events are only removed from the
+	// outbox once acked, and a failed one may then be published after later events.
+	// At most maxPending publishes may await an ack before publishing blocks.
+	cfg.nats.asyncPublish = getEnvBool("NATS_ASYNC_PUBLISH", false)
+	cfg.nats.maxPending = getEnvInt("NATS_MAX_PENDING_PUBLISHES", 256)
+	if cfg.nats.maxPending <= 0 {
+		cfg.nats.maxPending = 256
+	}
+	// How long shutdown waits for pending async publishes to be acked.
+	cfg.nats.drainTimeout = getEnvDuration("NATS_PUBLISH_DRAIN_TIMEOUT", 10*time.Second)
+
+	// How often the outbox worker retries events that haven't been published yet.
+	cfg.outbox.pollInterval = getEnvDuration("OUTBOX_POLL_INTERVAL", 5*time.Second)
+	if cfg.outbox.pollInterval <= 0 {
+		cfg.outbox.pollInterval = 5 * time.Second
+	}
+
+	// Per-client-IP rate limiting; RATE_LIMIT_RPS=0 disables it.
+	cfg.limiter.rps = getEnvFloat("RATE_LIMIT_RPS", 10)
+	cfg.limiter.burst = getEnvInt("RATE_LIMIT_BURST", 20)
+	cfg.limiter.enabled = cfg.limiter.rps > 0
+	cfg.limiter.trustForwardedFor = getEnvBool("RATE_LIMIT_TRUST_FORWARDED_FOR", false)
+
+	// Completed todos older than the retention are purged periodically; a zero
+	// retention disables the cleanup job.
+	cfg.cleanup.completedRetention = getEnvDuration("COMPLETED_RETENTION", 0)
+	// Soft-deleted todos are likewise purged for good once older than
+	// DELETED_RETENTION; zero keeps them forever.
+	cfg.cleanup.deletedRetention = getEnvDuration("DELETED_RETENTION", 0)
+	cfg.cleanup.interval = getEnvDuration("COMPLETED_CLEANUP_INTERVAL", time.Hour)
+	if cfg.cleanup.interval <= 0 {
+		cfg.cleanup.interval = time.Hour
+	}
+
+	// Seconds browsers may cache a CORS preflight response.
+	cfg.cors.maxAge = getEnvInt("CORS_MAX_AGE", 600)
+
+	// Longest description accepted, in characters. The frontend's MAX_DESCRIPTION_LEN
+	// must be set to the same value so its form doesn't allow more.
+	cfg.todos.maxDescriptionLength = getEnvInt("MAX_DESCRIPTION_LEN", data.DefaultMaxDescriptionLength)
+	if cfg.todos.maxDescriptionLength <= 0 {
+		cfg.todos.maxDescriptionLength = data.DefaultMaxDescriptionLength
+	}
+
+	// Largest number of todos accepted by a single POST /todos/bulk request.
+	cfg.bulk.maxItems = getEnvInt("BULK_MAX_ITEMS", 100)
+	if cfg.bulk.maxItems <= 0 {
+		cfg.bulk.maxItems = 100
+	}
+
+	// Largest number of rows accepted by a single POST /todos/import request.
+	cfg.csvImport.maxRows = getEnvInt("IMPORT_MAX_ROWS", 1000)
+	if cfg.csvImport.maxRows <= 0 {
+		cfg.csvImport.maxRows = 1000
+	}
+	// Largest body accepted by a single POST /todos/import request, in bytes.
+	cfg.csvImport.maxBytes = int64(getEnvInt("IMPORT_MAX_BYTES", 10_485_760))
+	if cfg.csvImport.maxBytes <= 0 {
+		cfg.csvImport.maxBytes = 10_485_760
+	}
+
+	// Most non-deleted todos a single owner may have; 0 means no limit.
+	cfg.quota.maxTodosPerOwner = getEnvInt("MAX_TODOS_PER_OWNER", 0)
+
+	// HS256 secret for bearer token authentication; unset disables authentication.
+	cfg.jwt.secret = os.Getenv("JWT_SECRET")
+
+	// Serve the OpenAPI spec and Swagger UI; off by default for production images.
+	cfg.docs.enabled = getEnvBool("API_DOCS_ENABLED", false)
+
+	// Demo todos are only created on an empty table when asked for, or by default in
+	// local development, so an intentionally empty production list stays empty.
+	env := strings.ToLower(getEnv("ENVIRONMENT", "production"))
+	cfg.seed.sampleTodos = getEnvBool("SEED_SAMPLE_TODOS", env == "local" || env == "development")
+
+	// DELETE /todos/completed publishes one "deleted" event per todo, or with
+	// CLEAR_COMPLETED_EVENTS=summary a single "cleared" event carrying the count.
+	switch value := strings.ToLower(getEnv("CLEAR_COMPLETED_EVENTS", "each")); value {
+	case "each", "summary":
+		cfg.clearCompleted.summaryEvent = value == "summary"
+	default:
+		log.Printf("Invalid CLEAR_COMPLETED_EVENTS %q, using each", value)
+	}
+
+	// Compress responses for clients sending Accept-Encoding: gzip.
+	cfg.gzip.enabled = getEnvBool("GZIP_ENABLED", true)
+
+	// Profiling handlers on a separate admin listener; off by default, and bound to
+	// loopback unless PPROF_ADDR says otherwise, so reach it with kubectl port-forward.
+	cfg.pprof.enabled = getEnvBool("ENABLE_PPROF", false)
+	cfg.pprof.addr = getEnv("PPROF_ADDR", "localhost:6060")
+
+	// JetStream stream settings, shared with the broadcaster.
+	cfg.stream = streamconfig.FromEnv()
+
+	return cfg
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %g", key, value, defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"todo-backend/internal/data"
+)
+
+// contextKey is a private type for the request context keys set by this package, so
+// they can't collide with keys set by other packages.
+type contextKey string
+
+const (
+	subjectContextKey   = contextKey("subject")
+	requestIDContextKey = contextKey("request_id")
+)
+
+// contextSetSubject returns a copy of the request with the authenticated subject
+// added to its context.
+func (app *application) contextSetSubject(r *http.Request, subject string) *http.Request {
+	ctx := context.WithValue(r.Context(), subjectContextKey, subject)
+	return r.WithContext(ctx)
+}
+
+// contextGetSubject returns the authenticated subject, or "" if the request wasn't
+// authenticated (i.e. authentication is disabled).
+func (app *application) contextGetSubject(r *http.Request) string {
+	subject, _ := r.Context().Value(subjectContextKey).(string)
+	return subject
+}
+
+// owner returns the owner whose todos the request may access: the authenticated
+// subject, or the shared public owner when authentication is disabled.
+func (app *application) owner(r *http.Request) string {
+	if subject := app.contextGetSubject(r); subject != "" {
+		return subject
+	}
+	return data.PublicOwner
+}
+
+// contextSetRequestID returns a copy of the request with its request ID added to its
+// context.
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// requestIDFromContext returns the ID of the request that ctx was derived from, or ""
+// outside of a request.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
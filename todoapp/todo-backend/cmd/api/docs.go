@@ -0,0 +1,28 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/swaggest/swgui/v5emb"
+)
+
+// openAPISpec describes the API. Keep it in step with the handlers in todos.go.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// swaggerUI renders openAPISpec from assets embedded in the binary, so /docs works
+// without reaching a CDN.
+var swaggerUI = v5emb.New("Todo backend API", "/openapi.yaml", "/docs/")
+
+// openAPIHandler handles GET /openapi.yaml
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"slices"
 )
 
 // The logError() method is a generic helper for logging an error message. Later in the
@@ -10,19 +11,28 @@ import (
 // about the request including the HTTP method and URL.
 func (app *application) logError(r *http.Request, err error) {
 	// Use the PrintError() method to log the error message, and include the current
-	// request method and URL as properties in the log entry.
+	// request method, URL and ID as properties in the log entry.
 	app.logger.PrintError(err, map[string]string{
 		"request_method": r.Method,
 		"request_url":    r.URL.String(),
+		"request_id":     requestIDFromContext(r.Context()),
 	})
 }
 
+// errorPayload is the body of every error response, sent as {"error": {...}}. Code
+// is a stable machine-readable identifier, Message is meant for humans, and Fields
+// carries per-field validation errors when there are any.
+type errorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Fields  any    `json:"fields,omitempty"`
+}
+
 // The errorResponse() method is a generic helper for sending JSON-formatted error
-// messages to the client with a given status code. Note that we're using an any
-// type for the message parameter, rather than just a string type, as this gives us
-// more flexibility over the values that we can include in the response.
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
-	env := envelope{"error": message}
+// messages to the client with a given status code. Every error goes through here,
+// so clients can always parse failures the same way.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, payload errorPayload) {
+	env := envelope{"error": payload}
 	// Write the response using the writeJSON() helper. If this happens to return an
 	// error then log it, and fall back to sending the client an empty response with a
 	// 500 Internal Server Error status code.
@@ -40,27 +50,105 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.errorResponse(w, r, http.StatusInternalServerError, errorPayload{Code: "internal_error", Message: message})
 }
 
 // The notFoundResponse() method will be used to send a 404 Not Found status code and
 // JSON response to the client.
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := "the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.errorResponse(w, r, http.StatusNotFound, errorPayload{Code: "not_found", Message: message})
 }
 
 // The methodNotAllowedResponse() method will be used to send a 405 Method Not Allowed
 // status code and JSON response to the client.
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, errorPayload{Code: "method_not_allowed", Message: message})
+}
+
+// The rateLimitExceededResponse() method will be used to send a 429 Too Many Requests
+// status code and JSON response to the client.
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "rate limit exceeded"
+	app.errorResponse(w, r, http.StatusTooManyRequests, errorPayload{Code: "rate_limited", Message: message})
+}
+
+// The invalidAuthenticationTokenResponse() method will be used to send a 401
+// Unauthorized status code when the bearer token is missing or invalid. The
+// WWW-Authenticate header tells the client which scheme we expect.
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := "invalid or missing authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, errorPayload{Code: "unauthorized", Message: message})
+}
+
+// The quotaExceededResponse() method will be used to send a 409 Conflict status code
+// when creating todos would take the owner past MAX_TODOS_PER_OWNER.
+func (app *application) quotaExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("you cannot have more than %d todos; delete some before creating more", app.config.quota.maxTodosPerOwner)
+	app.errorResponse(w, r, http.StatusConflict, errorPayload{Code: "quota_exceeded", Message: message})
 }
 
+// The badRequestResponse() method will be used to send a 400 Bad Request status code
+// with the error's text as the message.
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	app.errorResponse(w, r, http.StatusBadRequest, errorPayload{Code: "bad_request", Message: err.Error()})
+}
+
+// The failedValidationResponse() method will be used to send a 422 Unprocessable
+// Entity status code, listing the validation errors in fields.
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors any) {
+	message := "the request failed validation"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, errorPayload{Code: "validation_failed", Message: message, Fields: errors})
+}
+
+// itemError is a validation error for one element of an array request body.
+type itemError struct {
+	Index   int    `json:"index"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// itemErrors flattens the validation errors of the element at index into
+// itemErrors, ordered by field.
+func itemErrors(index int, errs map[string]string) []itemError {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	items := make([]itemError, len(fields))
+	for i, field := range fields {
+		items[i] = itemError{Index: index, Field: field, Message: errs[field]}
+	}
+	return items
+}
+
+// The failedItemValidationResponse() method is the variant of
+// failedValidationResponse() for array request bodies, listing each error with the
+// index of the element it belongs to.
+func (app *application) failedItemValidationResponse(w http.ResponseWriter, r *http.Request, errors []itemError) {
+	app.failedValidationResponse(w, r, errors)
+}
+
+// The serviceUnavailableResponse() method will be used to send a 503 Service
+// Unavailable status code when a dependency the request needs is down.
+func (app *application) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request, message string) {
+	app.errorResponse(w, r, http.StatusServiceUnavailable, errorPayload{Code: "unavailable", Message: message})
+}
+
+// The payloadTooLargeResponse() method will be used to send a 413 Content Too Large
+// status code when the request body is over its size limit.
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, limit int64) {
+	message := fmt.Sprintf("the request body must not be larger than %d bytes", limit)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, errorPayload{Code: "payload_too_large", Message: message})
 }
 
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+// The unsupportedMediaTypeResponse() method will be used to send a 415 Unsupported
+// Media Type status code when the request body isn't in the expected format.
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, contentType string) {
+	message := fmt.Sprintf("the request body must be %s", contentType)
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, errorPayload{Code: "unsupported_media_type", Message: message})
 }
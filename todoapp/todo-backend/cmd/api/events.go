@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// sseKeepAliveInterval is how often an idle event stream gets a comment line, so
+// proxies and load balancers don't close the connection as idle.
+const sseKeepAliveInterval = 15 * time.Second
+
+// todoEventsHandler handles GET /todos/events, streaming the requesting owner's todo
+// events as Server-Sent Events. It uses a plain core NATS subscription rather than a
+// JetStream consumer: a live view only needs events from now on, and there is
+// nothing to acknowledge or clean up when the client goes away.
+func (app *application) todoEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if app.nc == nil {
+		app.serviceUnavailableResponse(w, r, "event stream is unavailable")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream is long-lived, so lift any server write deadline for this response.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	msgs := make(chan *nats.Msg, 64)
+	sub, err := app.nc.ChanSubscribe(app.config.stream.Subject, msgs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	owner := app.owner(r)
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-app.closing:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case msg := <-msgs:
+			var event TodoMessage
+			if err := json.Unmarshal(msg.Data, &event); err != nil || event.Owner != owner {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", msg.Data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// auditEventsHandler handles GET /events?todo_id=, returning the audit log of a
+// todo's events oldest first. Unlike the todo itself, the history is still there
+// after the todo has been purged.
+func (app *application) auditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	todoID, err := strconv.Atoi(r.URL.Query().Get("todo_id"))
+	if err != nil || todoID < 1 {
+		app.badRequestResponse(w, r, errors.New("todo_id must be a positive integer"))
+		return
+	}
+
+	events, err := app.store.EventsForTodo(r.Context(), app.owner(r), todoID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"events": events}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
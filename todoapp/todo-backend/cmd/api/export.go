@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"todo-backend/internal/data"
+	"todo-backend/internal/validator"
+)
+
+// exportTimeout bounds an export, which streams the whole list and so can outlast
+// the store's per-query timeout.
+const exportTimeout = 30 * time.Second
+
+// exportFormats holds the values accepted for the format query parameter.
+var exportFormats = []string{"json", "csv"}
+
+// csvHeader names the columns of a CSV export, in the order exportCSV writes them.
+var csvHeader = []string{"id", "title", "description", "completed", "due_date", "priority", "created_at", "updated_at"}
+
+// exportTodosHandler handles GET /todos/export?format=json|csv. It streams the
+// caller's todos as an attachment, row by row as they are read from the database.
+func (app *application) exportTodosHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	v := validator.New()
+	v.Check(validator.In(format, exportFormats...), "format", "format must be json or csv")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	// The server's write timeout would otherwise cut off long exports
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportTimeout))
+
+	w.Header().Set("Content-Disposition", `attachment; filename="todos.`+format+`"`)
+
+	var err error
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = app.exportCSV(ctx, w, app.owner(r))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		err = app.exportJSON(ctx, w, app.owner(r))
+	}
+
+	// Once rows have been written the status can't change, so a failure part way
+	// through can only be logged; the client sees a truncated file.
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// exportJSON writes the owner's todos as a JSON array.
+func (app *application) exportJSON(ctx context.Context, w http.ResponseWriter, owner string) error {
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	first := true
+	err := app.store.Each(ctx, owner, data.Filters{Sort: "created_at"}, func(todo *data.Todo) error {
+		if !first {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		first = false
+		return enc.Encode(todo)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write([]byte("]\n"))
+	return err
+}
+
+// exportCSV writes the owner's todos as CSV with a header row. Timestamps are
+// RFC 3339 and a todo without a due date has an empty due_date.
+func (app *application) exportCSV(ctx context.Context, w http.ResponseWriter, owner string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	err := app.store.Each(ctx, owner, data.Filters{Sort: "created_at"}, func(todo *data.Todo) error {
+		dueDate := ""
+		if todo.DueDate != nil {
+			dueDate = todo.DueDate.Format(time.RFC3339)
+		}
+
+		return cw.Write([]string{
+			strconv.Itoa(todo.ID),
+			todo.Title,
+			todo.Description,
+			strconv.FormatBool(todo.Completed),
+			dueDate,
+			strconv.Itoa(todo.Priority),
+			todo.CreatedAt.Format(time.RFC3339),
+			todo.UpdatedAt.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -2,7 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"reflect"
+	"strings"
+	"time"
 )
 
 type envelope map[string]any
@@ -30,3 +36,133 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 	w.Write(js)
 	return nil
 }
+
+// background runs fn in a goroutine tracked by app.wg, so graceful shutdown can wait
+// for it. A panic in fn is logged instead of taking down the whole process.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// maxBodyBytes caps the size of JSON request bodies.
+const maxBodyBytes = 1_048_576
+
+// readJSON reads a single JSON value from the request body. Malformed or oversized
+// bodies are reported with a message suitable for a 400 response; checking the
+// value's shape is left to decodeObject.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request) (json.RawMessage, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		var syntaxError *json.SyntaxError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return nil, fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, errors.New("body contains badly-formed JSON")
+		case errors.Is(err, io.EOF):
+			return nil, errors.New("body must not be empty")
+		case errors.As(err, &maxBytesError):
+			return nil, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return nil, err
+		}
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return nil, errors.New("body must only contain a single JSON value")
+	}
+
+	return raw, nil
+}
+
+// decodeObject decodes a JSON object into dst, a pointer to a struct, field by field
+// so that every problem is reported at once: unknown fields and values of the wrong
+// type are returned keyed by field name. An error is returned only if raw isn't an
+// object at all.
+func decodeObject(raw json.RawMessage, dst any) (map[string]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return nil, errors.New("body must be a JSON object")
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	known := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			known[name] = i
+		}
+	}
+
+	fieldErrors := make(map[string]string)
+	for name, value := range fields {
+		i, ok := known[name]
+		if !ok {
+			fieldErrors[name] = "unknown field"
+			continue
+		}
+		field := v.Field(i)
+		if err := json.Unmarshal(value, field.Addr().Interface()); err != nil {
+			fieldErrors[name] = "must be " + describeType(field.Type())
+		}
+	}
+
+	return fieldErrors, nil
+}
+
+// nullFields returns the names of raw's fields that are explicitly null.
+// decodeObject leaves those fields at their zero value, the same as absent ones, so
+// this is how a handler tells "clear this field" apart from "leave it alone".
+func nullFields(raw json.RawMessage) map[string]bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+
+	null := make(map[string]bool)
+	for name, value := range fields {
+		if string(value) == "null" {
+			null[name] = true
+		}
+	}
+	return null
+}
+
+// describeType names the JSON value expected for a Go type in error messages.
+func describeType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return "an RFC 3339 timestamp"
+	case t.Kind() == reflect.String:
+		return "a string"
+	case t.Kind() == reflect.Bool:
+		return "a boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "an integer"
+	case t.Kind() == reflect.Slice:
+		return "an array of " + strings.TrimPrefix(strings.TrimPrefix(describeType(t.Elem()), "a "), "an ") + "s"
+	default:
+		return "a " + t.Kind().String()
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"todo-backend/internal/data"
+	"todo-backend/internal/validator"
+)
+
+// importError reports a CSV row that was skipped by POST /todos/import.
+type importError struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// importTodosHandler handles POST /todos/import. The body is CSV whose header row
+// names the columns: title and description are required, completed is optional and
+// any others are ignored, so the output of GET /todos/export can be imported as is.
+// Invalid rows are skipped and reported; the valid ones are inserted in one
+// transaction. A body over IMPORT_MAX_BYTES is rejected with a 413.
+func (app *application) importTodosHandler(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "text/csv" {
+		app.unsupportedMediaTypeResponse(w, r, "text/csv")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.csvImport.maxBytes)
+	cr := csv.NewReader(r.Body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.payloadTooLargeResponse(w, r, maxBytesError.Limit)
+			return
+		}
+		if errors.Is(err, io.EOF) {
+			err = errors.New("request body must contain a CSV header row")
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	columns, err := importColumns(header)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	owner := app.owner(r)
+	rowErrors := []importError{}
+	var todos []*data.Todo
+	for rows := 0; ; rows++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if rows == app.config.csvImport.maxRows {
+			app.badRequestResponse(w, r, fmt.Errorf("request body cannot contain more than %d rows", app.config.csvImport.maxRows))
+			return
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrors = append(rowErrors, importError{Line: parseErr.StartLine, Reason: parseErr.Err.Error()})
+			continue
+		}
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.payloadTooLargeResponse(w, r, maxBytesError.Limit)
+			return
+		}
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		line, _ := cr.FieldPos(0)
+		todo, reason := columns.todo(record, app.config.todos.maxDescriptionLength)
+		if reason != "" {
+			rowErrors = append(rowErrors, importError{Line: line, Reason: reason})
+			continue
+		}
+		todo.Owner = owner
+		todos = append(todos, todo)
+	}
+	if len(todos) > 0 {
+		err = app.store.WithTx(r.Context(), func(tx *data.TodoStore) error {
+			if err := tx.CreateMany(r.Context(), todos); err != nil {
+				return err
+			}
+			if err := app.checkQuota(r.Context(), tx, owner); err != nil {
+				return err
+			}
+			for _, todo := range todos {
+				if err := app.enqueueTodoEvent(r.Context(), tx, "created", todo); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, data.ErrQuotaExceeded) {
+				app.quotaExceededResponse(w, r)
+				return
+			}
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.notifyOutbox()
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{
+		"imported": len(todos),
+		"skipped":  len(rowErrors),
+		"errors":   rowErrors,
+	}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// csvColumns holds the index of each column the import reads; completed is -1 when
+// the upload has no such column.
+type csvColumns struct {
+	title, description, completed int
+}
+
+// importColumns locates the known columns in a CSV header row.
+func importColumns(header []string) (csvColumns, error) {
+	columns := csvColumns{title: -1, description: -1, completed: -1}
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "title":
+			columns.title = i
+		case "description":
+			columns.description = i
+		case "completed":
+			columns.completed = i
+		}
+	}
+
+	if columns.title < 0 || columns.description < 0 {
+		return columns, errors.New("CSV header must include title and description columns")
+	}
+	return columns, nil
+}
+
+// todo builds a todo from a CSV record, or returns why the record was rejected.
+func (c csvColumns) todo(record []string, maxDescriptionLength int) (*data.Todo, string) {
+	if len(record) <= max(c.title, c.description, c.completed) {
+		return nil, "row has fewer columns than the header"
+	}
+
+	req := CreateTodoRequest{
+		Title:       data.NormalizeTitle(record[c.title]),
+		Description: record[c.description],
+	}
+
+	v := validator.New()
+	validateCreateTodoRequest(v, req, maxDescriptionLength)
+
+	completed := false
+	if c.completed >= 0 && record[c.completed] != "" {
+		var err error
+		completed, err = strconv.ParseBool(record[c.completed])
+		v.Check(err == nil, "completed", "completed must be true or false")
+	}
+
+	if !v.Valid() {
+		return nil, joinErrors(v.Errors)
+	}
+
+	return &data.Todo{
+		Title:       req.Title,
+		Description: req.Description,
+		Completed:   completed,
+	}, ""
+}
+
+// joinErrors flattens validation errors into one message, ordered by field.
+func joinErrors(errs map[string]string) string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = errs[field]
+	}
+	return strings.Join(messages, "; ")
+}
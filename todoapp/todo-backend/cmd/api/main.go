@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"streamconfig"
 	"sync"
 	"time"
 	"todo-backend/internal/data"
@@ -17,42 +19,35 @@ import (
 )
 
 type application struct {
+	config config
 	logger *jsonlog.Logger
 	store  data.TodoStore
 	nc     *nats.Conn
 	js     nats.JetStreamContext
 	wg     sync.WaitGroup
 	db     *sql.DB
-}
 
-// Trigger Github actions GKE Deployment IV
-// corsMiddleware adds CORS headers
-func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next(w, r)
-	}
+	// done is closed on shutdown to stop background workers.
+	done         chan struct{}
+	outboxNotify chan struct{}
+	// closing is closed as soon as shutdown starts, so long-lived event streams end
+	// instead of holding up the server's graceful shutdown.
+	closing chan struct{}
 }
 
+// Trigger Github actions GKE Deployment IV
 func rootHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "Todo App backend - OK\n")
 }
 
-func setupNATSWithJetStream() (*nats.Conn, nats.JetStreamContext, error) {
+func setupNATSWithJetStream(cfg config) (*nats.Conn, nats.JetStreamContext, error) {
 	natsURL := getEnv("NATS_URL", "nats://my-nats:4222")
 
 	// Connect to NATS with connection options
-	nc, err := nats.Connect(
-		natsURL,
+	opts := []nats.Option{
 		nats.MaxReconnects(-1),
-		nats.ReconnectWait(2*time.Second),
+		nats.ReconnectWait(2 * time.Second),
 		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
 			if err != nil {
 				log.Printf("NATS disconnected: %v", err)
@@ -61,52 +56,58 @@ func setupNATSWithJetStream() (*nats.Conn, nats.JetStreamContext, error) {
 		nats.ReconnectHandler(func(nc *nats.Conn) {
 			log.Printf("NATS reconnected to %s", nc.ConnectedUrl())
 		}),
-	)
+	}
+	nc, err := nats.Connect(natsURL, append(opts, cfg.nats.connectOptions...)...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to connect to NATS: %w", err)
 	}
 
 	log.Printf("Connected to NATS at %s", natsURL)
 
-	// Create JetStream context
-	js, err := nc.JetStream()
+	// Create JetStream context. Failed async acks are also logged by the handler, as
+	// the outbox stops waiting for them after NATS_PUBLISH_TIMEOUT.
+	js, err := nc.JetStream(
+		nats.PublishAsyncMaxPending(cfg.nats.maxPending),
+		nats.PublishAsyncErrHandler(func(_ nats.JetStream, msg *nats.Msg, err error) {
+			log.Printf("Async publish to %s failed (request %s): %v", msg.Subject, msg.Header.Get(requestIDHeader), err)
+		}),
+	)
 	if err != nil {
 		nc.Close()
 		return nil, nil, fmt.Errorf("failed to create JetStream context: %w", err)
 	}
 
 	// Create stream for todo events if it doesn't exist
-	streamName := getEnv("STREAM_NAME", "TODOS")
-	streamSubject := getEnv("NATS_SUBJECT", "todos.events")
+	if err := streamconfig.Ensure(js, cfg.stream); err != nil {
+		nc.Close()
+		return nil, nil, err
+	}
 
-	stream, err := js.StreamInfo(streamName)
-	if err != nil {
-		// Stream doesn't exist, create it
-		streamConfig := &nats.StreamConfig{
-			Name:      streamName,
-			Subjects:  []string{streamSubject},
-			Storage:   nats.FileStorage,
-			MaxAge:    24 * time.Hour,
-			Retention: nats.WorkQueuePolicy, // Messages removed after acknowledgment
-			Replicas:  1,
-		}
+	return nc, js, nil
+}
 
-		_, err = js.AddStream(streamConfig)
-		if err != nil {
-			nc.Close()
-			return nil, nil, fmt.Errorf("failed to create stream: %w", err)
+// connectNATS retries setupNATSWithJetStream with exponential backoff, so a NATS
+// restart while the backend starts doesn't crash-loop it.
+func connectNATS(cfg config) (*nats.Conn, nats.JetStreamContext, error) {
+	backoff := cfg.nats.connectBackoff
+
+	for attempt := 0; ; attempt++ {
+		nc, js, err := setupNATSWithJetStream(cfg)
+		if err == nil || attempt >= cfg.nats.connectRetries {
+			return nc, js, err
 		}
-		log.Printf("Created JetStream stream: %s", streamName)
-	} else {
-		log.Printf("Using existing JetStream stream: %s (messages: %d)", streamName, stream.State.Msgs)
-	}
 
-	return nc, js, nil
+		log.Printf("NATS setup failed (attempt %d of %d), retrying in %s: %v",
+			attempt+1, cfg.nats.connectRetries+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
 }
 
 // createSampleTodos creates some sample todos if the table is empty
 func (app *application) createSampleTodos() {
-	todos, err := app.store.GetAll()
+	ctx := context.Background()
+	todos, err := app.store.GetAll(ctx, data.PublicOwner, data.Filters{})
 	if err != nil {
 		log.Printf("Error checking for existing todos: %v", err)
 		return
@@ -121,7 +122,7 @@ func (app *application) createSampleTodos() {
 			{"Finish project", "Complete the todo backend service"},
 		}
 		for _, todo := range sampleTodos {
-			_, err := app.store.Create(todo.title, todo.description)
+			err := app.store.Create(ctx, &data.Todo{Owner: data.PublicOwner, Title: todo.title, Description: todo.description})
 			if err != nil {
 				log.Printf("Error creating sample todo: %v", err)
 			}
@@ -129,50 +130,69 @@ func (app *application) createSampleTodos() {
 	}
 }
 
-// Readiness probe - checks if the app is ready to serve traffic
+// Readiness probe - checks if the app is ready to serve traffic. The database is
+// always checked; NATS only when readiness.checkNATS is set, so deployments that
+// run without the broadcaster don't have to treat JetStream as a hard dependency.
 func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	ready := true
+	response := map[string]interface{}{}
 
-	// Check database connection
-	if app.db == nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "not ready",
-			"reason": "database not initialized",
-		})
-		return
+	// Check database connection and verify we can query it
+	var count int
+	switch {
+	case app.db == nil:
+		ready = false
+		response["database"] = "database not initialized"
+	default:
+		if err := app.db.PingContext(r.Context()); err != nil {
+			ready = false
+			response["database"] = "database connection failed: " + err.Error()
+		} else if err := app.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM todos").Scan(&count); err != nil {
+			ready = false
+			response["database"] = "database query failed: " + err.Error()
+		} else {
+			response["database"] = "ok"
+			response["todo_count"] = count
+		}
+	}
+
+	// Check NATS connection
+	switch {
+	case !app.config.readiness.checkNATS:
+		response["nats"] = "skipped"
+	case app.nc == nil && !app.config.nats.required:
+		response["nats"] = "disabled"
+	case app.nc == nil || !app.nc.IsConnected():
+		ready = false
+		response["nats"] = "not connected"
+	default:
+		response["nats"] = "ok"
 	}
 
-	// Verify database is reachable
-	if err := app.db.Ping(); err != nil {
+	w.Header().Set("Content-Type", "application/json")
+	if ready {
+		response["status"] = "ready"
+		w.WriteHeader(http.StatusOK)
+	} else {
+		response["status"] = "not ready"
 		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "not ready",
-			"reason": "database connection failed",
-			"error":  err.Error(),
-		})
-		return
 	}
+	json.NewEncoder(w).Encode(response)
+}
 
-	// Verify we can query the database
-	var count int
-	err := app.db.QueryRow("SELECT COUNT(*) FROM todos").Scan(&count)
-	if err != nil {
+// Health check endpoint (legacy)
+func (app *application) healthHandler(w http.ResponseWriter, r *http.Request) {
+	// Test database connection
+	if err := app.db.PingContext(r.Context()); err != nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(map[string]string{
-			"status": "not ready",
-			"reason": "database query failed",
-			"error":  err.Error(),
+			"status": "unhealthy",
+			"error":  "database connection failed",
 		})
 		return
 	}
-
-	// All checks passed
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":     "ready",
-		"todo_count": count,
-	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
 // Liveness probe - checks if the app is alive
@@ -185,8 +205,18 @@ func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func main() {
+	cfg := loadConfig()
+
+	// Authentication and TLS are checked up front: retrying can't fix them, even
+	// when NATS is optional
+	natsOptions, err := streamconfig.ConnectOptionsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid NATS connection settings: %v", err)
+	}
+	cfg.nats.connectOptions = natsOptions
+
 	// Initialize database
-	db, err := InitDB()
+	db, err := InitDB(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -194,86 +224,105 @@ func main() {
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
 
 	// Connect to NATS
-	nc, js, err := setupNATSWithJetStream()
+	nc, js, err := connectNATS(cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to NATS with JetStream:", err)
+		if cfg.nats.required {
+			log.Fatal("Failed to connect to NATS with JetStream:", err)
+		}
+		log.Printf("NATS unavailable, event publishing is disabled: %v", err)
+	} else {
+		defer nc.Close()
 	}
-	defer nc.Close()
 
 	app := &application{
+		config: cfg,
 		logger: logger,
 		store:  data.NewTodoStore(db),
 		nc:     nc,
 		js:     js,
 		db:     db,
+
+		done:         make(chan struct{}),
+		outboxNotify: make(chan struct{}, 1),
+		closing:      make(chan struct{}),
 	}
 	// Create sample todos if none exist
-	app.createSampleTodos()
-	// Set up routes
-	http.HandleFunc("/", corsMiddleware(rootHandler))
-	http.HandleFunc("/todos", corsMiddleware(app.todosHandler))
-	http.HandleFunc("/todos/", corsMiddleware(app.todosHandler))
-
-	// Health check endpoint (legacy)
-	http.HandleFunc("/health", corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		// Test database connection
-		if err := db.Ping(); err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			json.NewEncoder(w).Encode(map[string]string{
-				"status": "unhealthy",
-				"error":  "database connection failed",
-			})
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
-	}))
-
-	http.HandleFunc("/readiness", app.readinessHandler)
-	http.HandleFunc("/liveness", app.livenessHandler)
-
-	port := os.Getenv("PORT")
-	fmt.Printf("Todo backend service starting on port %s\n", port)
+	if cfg.seed.sampleTodos {
+		app.createSampleTodos()
+	}
+	fmt.Printf("Todo backend service starting on port %s\n", cfg.port)
 	fmt.Printf("Endpoints:\n")
-	fmt.Printf("  GET    /todos       - Fetch all todos\n")
+	fmt.Printf("  GET    /todos       - Fetch all todos (?sort=created_at|priority&include_deleted=true)\n")
 	fmt.Printf("  POST   /todos       - Create a new todo\n")
+	fmt.Printf("  POST   /todos/bulk  - Create several todos at once\n")
+	fmt.Printf("  POST   /todos/complete - Mark several todos as completed\n")
+	fmt.Printf("  GET    /todos/stats - Completed/pending todo counts\n")
+	fmt.Printf("  DELETE /todos/completed - Soft-delete all completed todos\n")
+	fmt.Printf("  GET    /todos/export - Download all todos (?format=json|csv)\n")
+	fmt.Printf("  POST   /todos/import - Create todos from a CSV upload\n")
+	fmt.Printf("  GET    /todos/events - Server-Sent Events stream of todo changes\n")
+	fmt.Printf("  GET    /todos/ws    - WebSocket stream of todo changes\n")
+	fmt.Printf("  GET    /todos/{id}  - Fetch a single todo\n")
+	fmt.Printf("  HEAD   /todos/{id}  - Check that a todo exists\n")
 	fmt.Printf("  PATCH  /todos/{id}  - Update todo completion status\n")
+	fmt.Printf("  DELETE /todos/{id}  - Soft-delete a todo\n")
+	fmt.Printf("  POST   /todos/{id}/restore - Restore a deleted todo\n")
+	fmt.Printf("  POST   /todos/{id}/toggle - Flip a todo's completion status\n")
+	fmt.Printf("  GET    /events?todo_id={id} - Audit log of a todo's events\n")
 	fmt.Printf("  GET    /health      - Health check\n")
 	fmt.Printf("  GET    /readiness   - Readiness probe\n")
 	fmt.Printf("  GET    /liveness    - Liveness probe\n")
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+
+	if cfg.docs.enabled {
+		fmt.Printf("  GET    /openapi.yaml - OpenAPI spec\n")
+		fmt.Printf("  GET    /docs/       - Swagger UI\n")
+	}
+
+	if cfg.jwt.secret != "" {
+		log.Printf("JWT authentication enabled for /todos endpoints")
+	}
+
+	// Publish todo events recorded in the outbox. Without NATS the events are still
+	// enqueued and wait there for a run that has a connection.
+	if app.js != nil {
+		app.background(app.runOutboxWorker)
+	}
+
+	// Purge old completed and soft-deleted todos, if a retention is configured
+	if cfg.cleanup.completedRetention > 0 || cfg.cleanup.deletedRetention > 0 {
+		log.Printf("Todo cleanup: completed_retention=%s deleted_retention=%s interval=%s",
+			cfg.cleanup.completedRetention, cfg.cleanup.deletedRetention, cfg.cleanup.interval)
+		app.background(app.runCleanupWorker)
+	}
+
+	// Profiling, opt-in and never on the public port
+	if cfg.pprof.enabled {
+		app.background(app.runPprofServer)
+	}
+
+	if err := app.serve(":"+cfg.port, app.routes()); err != nil {
+		log.Fatal(err)
+	}
 }
 
-// InitDB initializes the database connection and creates tables
-func InitDB() (*sql.DB, error) {
-	dbURL := os.Getenv("DATABASE_URL")
-	db, err := sql.Open("postgres", dbURL)
+// InitDB initializes the database connection and applies schema migrations
+func InitDB(cfg config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.db.dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
+	db.SetMaxOpenConns(cfg.db.maxOpenConns)
+	db.SetMaxIdleConns(cfg.db.maxIdleConns)
+	db.SetConnMaxLifetime(cfg.db.connMaxLifetime)
+	log.Printf("Database pool: max_open_conns=%d max_idle_conns=%d conn_max_lifetime=%s",
+		cfg.db.maxOpenConns, cfg.db.maxIdleConns, cfg.db.connMaxLifetime)
 	// Test the connection
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
-	// Create table if it doesn't exist
-	createTableSQL := `
-    CREATE TABLE IF NOT EXISTS todos (
-        id SERIAL PRIMARY KEY,
-        title VARCHAR(255) NOT NULL,
-        description TEXT,
-        completed BOOLEAN DEFAULT FALSE,
-        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-    )`
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create table: %v", err)
+	// Bring the schema up to date
+	if err := data.Migrate(context.Background(), db); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %v", err)
 	}
 	return db, nil
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todo-backend/internal/auth"
+
+	"golang.org/x/time/rate"
+)
+
+// corsMiddleware adds CORS headers. Preflight requests additionally get the
+// requested headers reflected back, so custom headers such as Idempotency-Key are
+// allowed, and an Access-Control-Max-Age so browsers can cache the result.
+func (app *application) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Expose-Headers", requestIDHeader)
+		if r.Method == http.MethodOptions {
+			if r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+					w.Header().Set("Access-Control-Allow-Headers", requested)
+				}
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(app.config.cors.maxAge))
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authenticate requires a valid HS256 bearer token and adds its subject to the
+// request context. It does nothing when JWT_SECRET is unset.
+func (app *application) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	if app.config.jwt.secret == "" {
+		return next
+	}
+	secret := []byte(app.config.jwt.secret)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		claims, err := auth.ParseHS256(token, secret, time.Now())
+		if err != nil {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		next(w, app.contextSetSubject(r, claims.Subject))
+	}
+}
+
+// recoverPanic turns a panic in a handler, or in the middleware it wraps, into a 500
+// response instead of letting it take down the connection. The error log entry
+// written by serverErrorResponse carries the stack trace of the panic.
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				// http.ErrAbortHandler deliberately aborts the response; let net/http
+				// handle it as usual
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				// Make Go's HTTP server close the connection after the response
+				w.Header().Set("Connection", "close")
+				app.serverErrorResponse(w, r, fmt.Errorf("panic: %v", err))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestIDHeader carries the request ID on HTTP requests and responses, and on the
+// NATS messages published for them.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs, which end up in logs and
+// message headers.
+const maxRequestIDLength = 128
+
+// requestID tags every request with an ID, taken from an incoming X-Request-ID
+// header or generated, and echoes it in the response so a client can correlate its
+// call with the access log and the notifications it triggers.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, app.contextSetRequestID(r, id))
+	})
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to reuse: not
+// empty, not too long and printable ASCII without spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// probePaths are the Kubernetes probe endpoints, which are excluded from access logs.
+var probePaths = map[string]bool{
+	"/health":    true,
+	"/readiness": true,
+	"/liveness":  true,
+}
+
+// responseWriter records the status code written by a handler, which
+// http.ResponseWriter doesn't otherwise expose.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. for Flush).
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack passes connection takeover through to the underlying writer. WebSocket
+// upgraders type-assert http.Hijacker directly instead of using ResponseController.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	// The handshake response is written on the raw connection, so record it here
+	rw.status = http.StatusSwitchingProtocols
+	rw.wroteHeader = true
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
+
+// logRequest writes an access log entry for every request except the probes.
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rw, r)
+
+		app.logger.PrintInfo("request completed", map[string]string{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      strconv.Itoa(rw.status),
+			"duration":    time.Since(start).String(),
+			"remote_addr": r.RemoteAddr,
+			"request_id":  requestIDFromContext(r.Context()),
+		})
+	})
+}
+
+// compressResponse gzips responses for clients that accept it. Content types that
+// are already compressed, and event streams, are sent as they are.
+func (app *application) compressResponse(next http.Handler) http.Handler {
+	if !app.config.gzip.enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		// WebSocket upgrades take over the raw connection, so leave them untouched
+		if !acceptsGzip(r) || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) == "gzip" {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// incompressibleTypes are content type prefixes gzip can't shrink, or that must be
+// delivered unbuffered (event streams).
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"application/gzip",
+	"application/zip",
+	"text/event-stream",
+}
+
+// gzipResponseWriter compresses the body once the handler has set its headers,
+// deciding at the first WriteHeader or Write whether compression applies.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	decided     bool
+	wroteHeader bool
+}
+
+func (gw *gzipResponseWriter) decide(status int) {
+	if gw.decided {
+		return
+	}
+	gw.decided = true
+
+	h := gw.Header()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" {
+		return
+	}
+	contentType := h.Get("Content-Type")
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return
+		}
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	gw.gz = gzip.NewWriter(gw.ResponseWriter)
+}
+
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	gw.decide(status)
+	gw.ResponseWriter.WriteHeader(status)
+}
+
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		if gw.Header().Get("Content-Type") == "" {
+			// Sniff before compressing, or net/http would sniff the gzip bytes
+			gw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(b)
+	}
+	return gw.gz.Write(b)
+}
+
+// Flush pushes buffered compressed data to the client before flushing the
+// underlying writer.
+func (gw *gzipResponseWriter) Flush() {
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	http.NewResponseController(gw.ResponseWriter).Flush()
+}
+
+// Close finishes the gzip stream, if the response was compressed.
+func (gw *gzipResponseWriter) Close() error {
+	if gw.gz == nil {
+		return nil
+	}
+	return gw.gz.Close()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// rateLimit applies a token-bucket limiter per client IP. Idle clients are evicted by
+// a background sweep so the map doesn't grow without bound.
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	if !app.config.limiter.enabled {
+		return next
+	}
+
+	type client struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+
+	var (
+		mu      sync.Mutex
+		clients = make(map[string]*client)
+	)
+
+	app.background(func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-app.done:
+				return
+			case <-ticker.C:
+			}
+
+			mu.Lock()
+			for ip, c := range clients {
+				if time.Since(c.lastSeen) > 3*time.Minute {
+					delete(clients, ip)
+				}
+			}
+			mu.Unlock()
+		}
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip := app.clientIP(r)
+
+		mu.Lock()
+		c, found := clients[ip]
+		if !found {
+			c = &client{limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)}
+			clients[ip] = c
+		}
+		c.lastSeen = time.Now()
+
+		// Reserve rather than Allow so we know how long the client should back off.
+		reservation := c.limiter.Reserve()
+		delay := reservation.Delay()
+		if !reservation.OK() || delay > 0 {
+			reservation.Cancel()
+			mu.Unlock()
+
+			retryAfter := int(math.Ceil(delay.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
+		mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address used as the rate-limit key. X-Forwarded-For is only
+// honoured when the backend sits behind a trusted proxy, since clients can set it
+// to anything.
+func (app *application) clientIP(r *http.Request) string {
+	if app.config.limiter.trustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"todo-backend/internal/data"
+
+	"github.com/nats-io/nats.go"
+)
+
+// outboxBatchSize caps how many events a single drain publishes.
+const outboxBatchSize = 100
+
+// notifyOutbox wakes the outbox worker after a write has committed, so events are
+// published straight away instead of waiting for the next poll.
+func (app *application) notifyOutbox() {
+	select {
+	case app.outboxNotify <- struct{}{}:
+	default:
+	}
+}
+
+// runOutboxWorker publishes outbox events to JetStream until the application shuts
+// down. Events that fail to publish stay in the outbox and are retried on the next
+// poll, which gives at-least-once delivery across NATS outages.
+func (app *application) runOutboxWorker() {
+	ticker := time.NewTicker(app.config.outbox.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.done:
+			// Flush whatever was committed before shutdown while NATS is still open.
+			app.drainOutbox()
+			return
+		case <-app.outboxNotify:
+		case <-ticker.C:
+		}
+		app.drainOutbox()
+	}
+}
+
+// drainOutbox publishes pending outbox events until the outbox is empty or a
+// publish fails.
+func (app *application) drainOutbox() {
+	for {
+		n, err := app.store.DrainOutbox(context.Background(), outboxBatchSize, app.publishEvents)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"component": "outbox",
+				"published": strconv.Itoa(n),
+			})
+			return
+		}
+		if n < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// publishEvents publishes a batch of outbox events, returning one error per event
+// attempted. Synchronously, each publish waits for its ack and the batch stops at
+// the first failure so events keep their order. With NATS_ASYNC_PUBLISH the batch
+// is sent before waiting for the acks, costing one round trip rather than one per
+// event; an event still only counts as published once its ack has arrived.
+// Without a NATS connection nothing is attempted, so the events stay queued.
+func (app *application) publishEvents(events []data.OutboxEvent) []error {
+	if app.js == nil {
+		return nil
+	}
+	if app.config.nats.asyncPublish {
+		return app.publishEventsAsync(events)
+	}
+
+	errs := make([]error, 0, len(events))
+	for _, event := range events {
+		err := app.publishTodoEvent(event.Payload, event.RequestID)
+		errs = append(errs, err)
+		if err != nil {
+			break
+		}
+	}
+	return errs
+}
+
+// publishEventsAsync sends events without waiting in between, then waits up to
+// NATS_PUBLISH_TIMEOUT for their acks. An event whose ack failed or didn't arrive
+// in time stays in the outbox.
+func (app *application) publishEventsAsync(events []data.OutboxEvent) []error {
+	futures := make([]nats.PubAckFuture, 0, len(events))
+	var sendErr error
+	for _, event := range events {
+		future, err := app.js.PublishMsgAsync(app.todoEventMsg(event.Payload, event.RequestID))
+		if err != nil {
+			// Later events aren't sent, but the acks of earlier ones still count
+			sendErr = fmt.Errorf("failed to publish to NATS JetStream: %w", err)
+			break
+		}
+		futures = append(futures, future)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.nats.publishTimeout)
+	defer cancel()
+
+	errs := make([]error, 0, len(futures)+1)
+	for _, future := range futures {
+		select {
+		case <-future.Ok():
+			errs = append(errs, nil)
+		case err := <-future.Err():
+			errs = append(errs, fmt.Errorf("failed to publish to NATS JetStream: %w", err))
+		case <-ctx.Done():
+			errs = append(errs, errors.New("timed out waiting for NATS JetStream ack"))
+		}
+	}
+	if sendErr != nil {
+		errs = append(errs, sendErr)
+	}
+	return errs
+}
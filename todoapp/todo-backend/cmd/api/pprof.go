@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// runPprofServer serves the net/http/pprof handlers on their own listener, away
+// from the public routes, until the application shuts down. Profiles such as
+// /debug/pprof/profile stream for as long as they were asked to, so the server
+// only bounds reading request headers.
+func (app *application) runPprofServer() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	srv := &http.Server{
+		Addr:              app.config.pprof.addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-app.done
+		srv.Close()
+	}()
+
+	log.Printf("pprof listening on %s", srv.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("pprof server failed: %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "net/http"
+
+// routes registers the API endpoints and wraps them in the global middleware.
+func (app *application) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", app.corsMiddleware(rootHandler))
+	mux.HandleFunc("/todos", app.corsMiddleware(app.authenticate(app.todosHandler)))
+	mux.HandleFunc("/todos/", app.corsMiddleware(app.authenticate(app.todosHandler)))
+	mux.HandleFunc("/events", app.corsMiddleware(app.authenticate(app.auditEventsHandler)))
+
+	mux.HandleFunc("/health", app.corsMiddleware(app.healthHandler))
+	mux.HandleFunc("/readiness", app.readinessHandler)
+	mux.HandleFunc("/liveness", app.livenessHandler)
+
+	if app.config.docs.enabled {
+		mux.HandleFunc("/openapi.yaml", app.openAPIHandler)
+		mux.Handle("/docs/", swaggerUI)
+	}
+
+	return app.requestID(app.recoverPanic(app.logRequest(app.rateLimit(app.compressResponse(mux)))))
+}
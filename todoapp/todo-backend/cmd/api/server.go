@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve runs the HTTP server until SIGINT/SIGTERM, then shuts it down gracefully and
+// waits for background workers to finish before returning.
+func (app *application) serve(addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       app.config.server.readTimeout,
+		ReadHeaderTimeout: app.config.server.readHeaderTimeout,
+		WriteTimeout:      app.config.server.writeTimeout,
+		IdleTimeout:       app.config.server.idleTimeout,
+	}
+	srv.RegisterOnShutdown(func() { close(app.closing) })
+
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		log.Printf("Shutting down server (signal: %s)", s)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+
+		// Stop background workers and wait for them to finish their current work.
+		close(app.done)
+		app.wg.Wait()
+
+		// The outbox flush above may have left async publishes awaiting acks; NATS is
+		// only closed once serve returns.
+		app.waitForAsyncPublishes()
+
+		shutdownError <- err
+	}()
+
+	log.Printf("Listening on %s", srv.Addr)
+
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-shutdownError; err != nil {
+		return err
+	}
+
+	log.Println("Server stopped")
+	return nil
+}
+
+// waitForAsyncPublishes waits up to NATS_PUBLISH_DRAIN_TIMEOUT for async publishes to
+// be acked, so events published just before shutdown aren't dropped.
+func (app *application) waitForAsyncPublishes() {
+	if app.js == nil || !app.config.nats.asyncPublish {
+		return
+	}
+
+	pending := app.js.PublishAsyncPending()
+	if pending == 0 {
+		return
+	}
+	log.Printf("Waiting for %d pending NATS publishes", pending)
+
+	select {
+	case <-app.js.PublishAsyncComplete():
+		log.Printf("Pending NATS publishes completed")
+	case <-time.After(app.config.nats.drainTimeout):
+		log.Printf("Timed out with %d NATS publishes still pending", app.js.PublishAsyncPending())
+	}
+}
@@ -1,34 +1,68 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 	"todo-backend/internal/data"
+	"todo-backend/internal/validator"
+
+	"github.com/nats-io/nats.go"
 )
 
 type CreateTodoRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    int        `json:"priority"`
 }
 
-// UpdateTodoRequest represents the request body for updating a todo
+// UpdateTodoRequest represents the request body for updating a todo. Fields that are
+// omitted from the request are left unchanged.
 type UpdateTodoRequest struct {
-	Completed bool `json:"completed"`
+	Title     *string    `json:"title"`
+	Completed *bool      `json:"completed"`
+	DueDate   *time.Time `json:"due_date"`
+	Priority  *int       `json:"priority"`
 }
 
 type TodoMessage struct {
-	Action      string `json:"action"`
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
+	Action      string     `json:"action"`
+	ID          int        `json:"id"`
+	Owner       string     `json:"owner"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Priority    int        `json:"priority"`
+	// Count is the number of todos affected by a "cleared" summary event
+	Count int `json:"count,omitempty"`
 }
 
 // getTodosHandler handles GET /todos
 func (app *application) getTodosHandler(w http.ResponseWriter, r *http.Request) {
-	todos, err := app.store.GetAll()
+	filters := data.Filters{Sort: r.URL.Query().Get("sort")}
+	if filters.Sort == "" {
+		filters.Sort = "created_at"
+	}
+
+	v := validator.New()
+	if value := r.URL.Query().Get("include_deleted"); value != "" {
+		includeDeleted, err := strconv.ParseBool(value)
+		v.Check(err == nil, "include_deleted", "include_deleted must be true or false")
+		filters.IncludeDeleted = includeDeleted
+	}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	todos, err := app.store.GetAll(r.Context(), app.owner(r), filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -41,76 +75,460 @@ func (app *application) getTodosHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// getTodoHandler handles GET /todos/{id}. Todos of other owners are reported as not
+// found rather than forbidden, so ids can't be probed across owners.
+func (app *application) getTodoHandler(w http.ResponseWriter, r *http.Request, id int) {
+	todo, err := app.store.GetByID(r.Context(), app.owner(r), id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(todo); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// headTodoHandler handles HEAD /todos/{id}, answering 200 or 404 like GET but without
+// a body. The todo is read and encoded as GET would, so Content-Length matches GET's
+// response.
+func (app *application) headTodoHandler(w http.ResponseWriter, r *http.Request, id int) {
+	todo, err := app.store.GetByID(r.Context(), app.owner(r), id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	// json.Encoder, as used by GET, ends the body with a newline
+	body, err := json.Marshal(todo)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)+1))
+	w.WriteHeader(http.StatusOK)
+}
+
+// getTodoStatsHandler handles GET /todos/stats
+func (app *application) getTodoStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.store.Stats(r.Context(), app.owner(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
 func (app *application) createTodoHandler(w http.ResponseWriter, r *http.Request) {
+	raw, err := app.readJSON(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	var req CreateTodoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	fieldErrors, err := decodeObject(raw, &req)
+	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	validationMessage := make(map[string]string)
+	req.Title = data.NormalizeTitle(req.Title)
+	idempotencyKey := r.Header.Get("Idempotency-Key")
 
-	// Validate required fields
-	if req.Title == "" {
-		validationMessage["title"] = "title is required"
+	// Type errors come first, so a wrongly typed field isn't also reported as missing
+	v := validator.New()
+	for field, message := range fieldErrors {
+		v.AddError(field, message)
 	}
+	validateCreateTodoRequest(v, req, app.config.todos.maxDescriptionLength)
+	v.Check(len(idempotencyKey) <= 255, "idempotency_key", "Idempotency-Key header cannot exceed 255 characters")
 
-	if req.Description == "" {
-		validationMessage["description"] = "Description is required"
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 
-	if len(req.Description) > 140 {
-		validationMessage["description"] = "Description cannot exceed 140 characters"
+	todo := &data.Todo{
+		Owner:       app.owner(r),
+		Title:       req.Title,
+		Description: req.Description,
+		DueDate:     utcTime(req.DueDate),
+		Priority:    req.Priority,
 	}
 
-	if len(validationMessage) > 0 {
-		app.failedValidationResponse(w, r, validationMessage)
+	// Insert the todo and record its creation event in the same transaction, so the
+	// event survives a NATS outage; the outbox worker publishes it to JetStream.
+	created := true
+	err = app.store.WithTx(r.Context(), func(tx *data.TodoStore) error {
+		var err error
+		if idempotencyKey != "" {
+			created, err = tx.CreateIdempotent(r.Context(), todo, idempotencyKey)
+		} else {
+			err = tx.Create(r.Context(), todo)
+		}
+		if err != nil || !created {
+			return err
+		}
+		if err := app.checkQuota(r.Context(), tx, todo.Owner); err != nil {
+			return err
+		}
+		return app.enqueueTodoEvent(r.Context(), tx, "created", todo)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrQuotaExceeded) {
+			app.quotaExceededResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
 		return
 	}
+	app.notifyOutbox()
 
-	todo, err := app.store.Create(req.Title, req.Description)
-	if err != nil {
+	// A retry of an earlier request: hand back the original todo without inserting
+	// or announcing a duplicate.
+	if !created {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(todo); err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(todo); err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+}
 
-	// Publish todo creation event to NATS
-	if err := app.publishTodoEvent("created", todo); err != nil {
-		// Log the error but don't fail the request
-		// You might want to use a proper logger here
-		fmt.Printf("Warning: failed to publish todo event: %v\n", err)
+// createTodosBulkHandler handles POST /todos/bulk. All todos are inserted in one
+// transaction; if any of them fails validation nothing is inserted and the errors
+// are returned keyed by array index.
+func (app *application) createTodosBulkHandler(w http.ResponseWriter, r *http.Request) {
+	raw, err := app.readJSON(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		app.badRequestResponse(w, r, errors.New("body must be a JSON array"))
+		return
+	}
+
+	if len(items) == 0 {
+		app.badRequestResponse(w, r, errors.New("request body must contain at least one todo"))
+		return
+	}
+	if len(items) > app.config.bulk.maxItems {
+		app.badRequestResponse(w, r, fmt.Errorf("request body cannot contain more than %d todos", app.config.bulk.maxItems))
+		return
+	}
+
+	owner := app.owner(r)
+	todos := make([]*data.Todo, len(items))
+	var errs []itemError
+	for i, item := range items {
+		var req CreateTodoRequest
+		fieldErrors, err := decodeObject(item, &req)
+		if err != nil {
+			errs = append(errs, itemError{Index: i, Message: "must be a JSON object"})
+			continue
+		}
+		req.Title = data.NormalizeTitle(req.Title)
+
+		v := validator.New()
+		for field, message := range fieldErrors {
+			v.AddError(field, message)
+		}
+		if validateCreateTodoRequest(v, req, app.config.todos.maxDescriptionLength); !v.Valid() {
+			errs = append(errs, itemErrors(i, v.Errors)...)
+			continue
+		}
+
+		todos[i] = &data.Todo{
+			Owner:       owner,
+			Title:       req.Title,
+			Description: req.Description,
+			DueDate:     utcTime(req.DueDate),
+			Priority:    req.Priority,
+		}
+	}
+
+	if len(errs) > 0 {
+		app.failedItemValidationResponse(w, r, errs)
+		return
+	}
+
+	err = app.store.WithTx(r.Context(), func(tx *data.TodoStore) error {
+		if err := tx.CreateMany(r.Context(), todos); err != nil {
+			return err
+		}
+		if err := app.checkQuota(r.Context(), tx, owner); err != nil {
+			return err
+		}
+		for _, todo := range todos {
+			if err := app.enqueueTodoEvent(r.Context(), tx, "created", todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrQuotaExceeded) {
+			app.quotaExceededResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.notifyOutbox()
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(todo); err != nil {
+	if err := json.NewEncoder(w).Encode(todos); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// CompleteTodosRequest represents the request body for POST /todos/complete
+type CompleteTodosRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// completeTodosHandler handles POST /todos/complete, marking several todos as done in
+// a single transaction. Ids that don't exist are reported in not_found instead of
+// failing the whole request.
+func (app *application) completeTodosHandler(w http.ResponseWriter, r *http.Request) {
+	raw, err := app.readJSON(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var req CompleteTodosRequest
+	fieldErrors, err := decodeObject(raw, &req)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	for field, message := range fieldErrors {
+		v.AddError(field, message)
+	}
+	v.Check(len(req.IDs) > 0, "ids", "at least one id is required")
+	v.Check(len(req.IDs) <= app.config.bulk.maxItems, "ids", fmt.Sprintf("cannot complete more than %d todos at once", app.config.bulk.maxItems))
+	for _, id := range req.IDs {
+		v.Check(id > 0, "ids", "ids must be positive integers")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var todos []data.Todo
+	err = app.store.WithTx(r.Context(), func(tx *data.TodoStore) error {
+		var err error
+		todos, err = tx.CompleteMany(r.Context(), app.owner(r), req.IDs)
+		if err != nil {
+			return err
+		}
+		for i := range todos {
+			if err := app.enqueueTodoEvent(r.Context(), tx, "completed", &todos[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	app.notifyOutbox()
+
+	found := make(map[int]bool, len(todos))
+	for _, todo := range todos {
+		found[todo.ID] = true
+	}
+	notFound := []int{}
+	for _, id := range req.IDs {
+		if !found[id] {
+			found[id] = true // report duplicate ids only once
+			notFound = append(notFound, id)
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"todos": todos, "not_found": notFound}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// validateCreateTodoRequest checks a create request whose title has already been
+// normalized.
+func validateCreateTodoRequest(v *validator.Validator, req CreateTodoRequest, maxDescriptionLength int) {
+	data.ValidateTitle(v, req.Title)
+
+	data.ValidateDescription(v, req.Description, maxDescriptionLength)
+	v.Check(req.DueDate == nil || req.DueDate.After(time.Now()), "due_date", "due date cannot be in the past")
+	data.ValidatePriority(v, req.Priority)
 }
 
 func (app *application) updateTodoHandler(w http.ResponseWriter, r *http.Request, id int) {
+	raw, err := app.readJSON(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	var req UpdateTodoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	fieldErrors, err := decodeObject(raw, &req)
+	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	todo, err := app.store.Update(id, req.Completed)
+	v := validator.New()
+	for field, message := range fieldErrors {
+		v.AddError(field, message)
+	}
+	if req.Title != nil {
+		title := data.NormalizeTitle(*req.Title)
+		req.Title = &title
+		data.ValidateTitle(v, title)
+	}
+	if req.Priority != nil {
+		data.ValidatePriority(v, *req.Priority)
+	}
+	v.Check(req.DueDate == nil || req.DueDate.After(time.Now()), "due_date", "due date cannot be in the past")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// A due_date of null removes the due date, while leaving it out keeps it
+	clearDueDate := nullFields(raw)["due_date"]
+
+	var todo *data.Todo
+	err = app.store.WithTx(r.Context(), func(tx *data.TodoStore) error {
+		var err error
+		todo, err = tx.UpdateFields(r.Context(), app.owner(r), id, data.TodoUpdate{
+			Title:        req.Title,
+			Completed:    req.Completed,
+			DueDate:      utcTime(req.DueDate),
+			ClearDueDate: clearDueDate,
+			Priority:     req.Priority,
+		})
+		if err != nil {
+			return err
+		}
+		return app.enqueueTodoEvent(r.Context(), tx, "updated", todo)
+	})
 	if err != nil {
-		if err.Error() == fmt.Sprintf("todo with id %d not found", id) {
+		if errors.Is(err, data.ErrRecordNotFound) {
 			app.notFoundResponse(w, r)
 			return
 		}
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	app.notifyOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(todo); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteTodoHandler handles DELETE /todos/{id}. The todo is only soft-deleted and
+// can be brought back with POST /todos/{id}/restore.
+func (app *application) deleteTodoHandler(w http.ResponseWriter, r *http.Request, id int) {
+	app.changeDeletedState(w, r, id, "deleted", (*data.TodoStore).Delete)
+}
+
+// restoreTodoHandler handles POST /todos/{id}/restore
+func (app *application) restoreTodoHandler(w http.ResponseWriter, r *http.Request, id int) {
+	app.changeDeletedState(w, r, id, "restored", (*data.TodoStore).Restore)
+}
+
+// toggleTodoHandler handles POST /todos/{id}/toggle, flipping completed in a single
+// UPDATE so two clients toggling at once can't both act on a stale read.
+func (app *application) toggleTodoHandler(w http.ResponseWriter, r *http.Request, id int) {
+	var todo *data.Todo
+	err := app.store.WithTx(r.Context(), func(tx *data.TodoStore) error {
+		var err error
+		todo, err = tx.Toggle(r.Context(), app.owner(r), id)
+		if err != nil {
+			return err
+		}
+		action := "updated"
+		if todo.Completed {
+			action = "completed"
+		}
+		return app.enqueueTodoEvent(r.Context(), tx, action, todo)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.notifyOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(todo); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
 
-	// Publish todo update event to NATS
-	if err := app.publishTodoEvent("updated", todo); err != nil {
-		// Log the error but don't fail the request
-		fmt.Printf("Warning: failed to publish todo event: %v\n", err)
+// changeDeletedState runs a soft delete or restore together with its event in one
+// transaction and responds with the affected todo.
+func (app *application) changeDeletedState(w http.ResponseWriter, r *http.Request, id int, action string,
+	change func(*data.TodoStore, context.Context, string, int) (*data.Todo, error)) {
+	var todo *data.Todo
+	err := app.store.WithTx(r.Context(), func(tx *data.TodoStore) error {
+		var err error
+		todo, err = change(tx, r.Context(), app.owner(r), id)
+		if err != nil {
+			return err
+		}
+		return app.enqueueTodoEvent(r.Context(), tx, action, todo)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
 	}
+	app.notifyOutbox()
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(todo); err != nil {
@@ -119,23 +537,117 @@ func (app *application) updateTodoHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
-func (app *application) publishTodoEvent(action string, todo *data.Todo) error {
-	msg := TodoMessage{
+// deleteCompletedTodosHandler handles DELETE /todos/completed, soft-deleting all of
+// the caller's completed todos at once.
+func (app *application) deleteCompletedTodosHandler(w http.ResponseWriter, r *http.Request) {
+	owner := app.owner(r)
+
+	var todos []data.Todo
+	err := app.store.WithTx(r.Context(), func(tx *data.TodoStore) error {
+		var err error
+		todos, err = tx.DeleteCompleted(r.Context(), owner)
+		if err != nil || len(todos) == 0 {
+			return err
+		}
+
+		if !app.config.clearCompleted.summaryEvent {
+			for i := range todos {
+				if err := app.enqueueTodoEvent(r.Context(), tx, "deleted", &todos[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// The audit log stays per todo; only the published event is summarised
+		for i := range todos {
+			payload, err := json.Marshal(todoMessage("deleted", &todos[i]))
+			if err != nil {
+				return fmt.Errorf("failed to marshal todo message: %w", err)
+			}
+			if err := tx.RecordEvent(r.Context(), "deleted", todos[i].ID, owner, payload); err != nil {
+				return fmt.Errorf("failed to record audit event: %w", err)
+			}
+		}
+
+		payload, err := json.Marshal(TodoMessage{Action: "cleared", Owner: owner, Count: len(todos)})
+		if err != nil {
+			return fmt.Errorf("failed to marshal todo message: %w", err)
+		}
+		return tx.EnqueueEvent(r.Context(), payload, requestIDFromContext(r.Context()))
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if len(todos) > 0 {
+		app.notifyOutbox()
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"deleted": len(todos)}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkQuota enforces MAX_TODOS_PER_OWNER on todos just inserted in tx.
+func (app *application) checkQuota(ctx context.Context, tx *data.TodoStore, owner string) error {
+	if app.config.quota.maxTodosPerOwner <= 0 {
+		return nil
+	}
+	return tx.CheckQuota(ctx, owner, app.config.quota.maxTodosPerOwner)
+}
+
+// enqueueTodoEvent records a todo event in the outbox and the audit log as part of
+// tx. The outbox worker publishes it to JetStream once the transaction has committed.
+func (app *application) enqueueTodoEvent(ctx context.Context, tx *data.TodoStore, action string, todo *data.Todo) error {
+	payload, err := json.Marshal(todoMessage(action, todo))
+	if err != nil {
+		return fmt.Errorf("failed to marshal todo message: %w", err)
+	}
+
+	if err := tx.RecordEvent(ctx, action, todo.ID, todo.Owner, payload); err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	return tx.EnqueueEvent(ctx, payload, requestIDFromContext(ctx))
+}
+
+// todoMessage builds the event published for an action on todo.
+func todoMessage(action string, todo *data.Todo) TodoMessage {
+	return TodoMessage{
 		Action:      action,
 		ID:          todo.ID,
+		Owner:       todo.Owner,
 		Title:       todo.Title,
 		Description: todo.Description,
 		Completed:   todo.Completed,
+		DueDate:     todo.DueDate,
+		Priority:    todo.Priority,
 	}
+}
 
-	data, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal todo message: %w", err)
+// todoEventMsg wraps an already-encoded TodoMessage for publishing, tagged with the
+// ID of the request that produced it.
+func (app *application) todoEventMsg(data []byte, requestID string) *nats.Msg {
+	msg := nats.NewMsg(app.config.stream.Subject)
+	msg.Data = data
+	if requestID != "" {
+		msg.Header.Set(requestIDHeader, requestID)
 	}
+	return msg
+}
+
+// publishTodoEvent publishes an already-encoded TodoMessage to JetStream and waits
+// for the ack.
+func (app *application) publishTodoEvent(data []byte, requestID string) error {
+	msg := app.todoEventMsg(data, requestID)
 
 	// Publish to NATS JetStream with acknowledgment
 	// JetStream ensures the message is persisted before returning
-	pubAck, err := app.js.Publish("todos.events", data)
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.nats.publishTimeout)
+	defer cancel()
+
+	pubAck, err := app.js.PublishMsg(msg, nats.Context(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to publish to NATS JetStream: %w", err)
 	}
@@ -147,6 +659,16 @@ func (app *application) publishTodoEvent(action string, todo *data.Todo) error {
 	return nil
 }
 
+// utcTime converts an optional timestamp to UTC. The due_date column is a plain
+// TIMESTAMP, which would otherwise silently drop the client's UTC offset.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
 // todosHandler handles all /todos routes
 func (app *application) todosHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse ID from path if present
@@ -154,19 +676,120 @@ func (app *application) todosHandler(w http.ResponseWriter, r *http.Request) {
 	var id int
 	var err error
 
+	if path == "/todos/stats" {
+		if r.Method != http.MethodGet {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.getTodoStatsHandler(w, r)
+		return
+	}
+
+	if path == "/todos/completed" {
+		if r.Method != http.MethodDelete {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.deleteCompletedTodosHandler(w, r)
+		return
+	}
+
+	if path == "/todos/export" {
+		if r.Method != http.MethodGet {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.exportTodosHandler(w, r)
+		return
+	}
+
+	if path == "/todos/import" {
+		if r.Method != http.MethodPost {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.importTodosHandler(w, r)
+		return
+	}
+
+	if path == "/todos/events" {
+		if r.Method != http.MethodGet {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.todoEventsHandler(w, r)
+		return
+	}
+
+	if path == "/todos/ws" {
+		if r.Method != http.MethodGet {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.todoWebSocketHandler(w, r)
+		return
+	}
+
+	if path == "/todos/bulk" {
+		if r.Method != http.MethodPost {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.createTodosBulkHandler(w, r)
+		return
+	}
+
+	if path == "/todos/complete" {
+		if r.Method != http.MethodPost {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.completeTodosHandler(w, r)
+		return
+	}
+
 	if len(path) > 7 { // "/todos/" is 7 characters
 		idStr := path[7:] // Extract everything after "/todos/"
+
+		// POST /todos/{id}/restore and POST /todos/{id}/toggle
+		idStr, restore := strings.CutSuffix(idStr, "/restore")
+		idStr, toggle := strings.CutSuffix(idStr, "/toggle")
+
 		id, err = strconv.Atoi(idStr)
 		if err != nil {
 			app.badRequestResponse(w, r, err)
 			return
 		}
+
+		if restore {
+			if r.Method != http.MethodPost {
+				app.methodNotAllowedResponse(w, r)
+				return
+			}
+			app.restoreTodoHandler(w, r, id)
+			return
+		}
+
+		if toggle {
+			if r.Method != http.MethodPost {
+				app.methodNotAllowedResponse(w, r)
+				return
+			}
+			app.toggleTodoHandler(w, r, id)
+			return
+		}
 	}
 
 	switch r.Method {
 	case http.MethodGet:
 		if id == 0 {
 			app.getTodosHandler(w, r)
+		} else {
+			app.getTodoHandler(w, r, id)
+		}
+	case http.MethodHead:
+		if id != 0 {
+			app.headTodoHandler(w, r, id)
 		} else {
 			app.methodNotAllowedResponse(w, r)
 		}
@@ -182,6 +805,12 @@ func (app *application) todosHandler(w http.ResponseWriter, r *http.Request) {
 		} else {
 			app.methodNotAllowedResponse(w, r)
 		}
+	case http.MethodDelete:
+		if id != 0 {
+			app.deleteTodoHandler(w, r, id)
+		} else {
+			app.methodNotAllowedResponse(w, r)
+		}
 	default:
 		app.methodNotAllowedResponse(w, r)
 	}
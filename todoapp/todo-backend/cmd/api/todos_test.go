@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"todo-backend/internal/jsonlog"
+)
+
+// newTestApplication returns an application with the default configuration and no
+// database or NATS, for exercising handlers up to the point they touch either.
+func newTestApplication() *application {
+	return &application{
+		config: loadConfig(),
+		logger: jsonlog.New(io.Discard, jsonlog.LevelInfo),
+	}
+}
+
+// postTodo sends body to createTodoHandler and returns the response.
+func postTodo(t *testing.T, app *application, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	app.createTodoHandler(rec, httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(string(payload))))
+	return rec
+}
+
+func TestCreateTodoTitleTooLong(t *testing.T) {
+	rec := postTodo(t, newTestApplication(), map[string]string{
+		"title":       strings.Repeat("a", 256),
+		"description": "too long a title",
+	})
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"title"`) {
+		t.Errorf("body doesn't report the title: %s", rec.Body)
+	}
+}
+
+func TestCreateTodoDescriptionOverConfiguredLimit(t *testing.T) {
+	t.Setenv("MAX_DESCRIPTION_LEN", "5")
+	app := newTestApplication()
+	if got := app.config.todos.maxDescriptionLength; got != 5 {
+		t.Fatalf("maxDescriptionLength = %d, want 5 from MAX_DESCRIPTION_LEN", got)
+	}
+
+	rec := postTodo(t, app, map[string]string{
+		"title":       "title",
+		"description": strings.Repeat("é", 6),
+	})
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"description"`) {
+		t.Errorf("body doesn't report the description: %s", rec.Body)
+	}
+}
+
+func TestUpdateTodoDueDateInThePast(t *testing.T) {
+	body := strings.NewReader(`{"due_date": "2000-01-01T00:00:00Z"}`)
+	rec := httptest.NewRecorder()
+	newTestApplication().updateTodoHandler(rec, httptest.NewRequest(http.MethodPatch, "/todos/1", body), 1)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"due_date"`) {
+		t.Errorf("body doesn't report the due date: %s", rec.Body)
+	}
+}
+
+func TestNullFields(t *testing.T) {
+	null := nullFields(json.RawMessage(`{"due_date": null, "title": "a", "priority": 1}`))
+
+	if !null["due_date"] {
+		t.Error("due_date = null isn't reported")
+	}
+	if null["title"] || null["priority"] || null["completed"] {
+		t.Errorf("null fields = %v, want only due_date", null)
+	}
+}
+
+func TestImportTodosBodyTooLarge(t *testing.T) {
+	t.Setenv("IMPORT_MAX_BYTES", "64")
+	app := newTestApplication()
+
+	body := "title,description\n" + strings.Repeat("a,b\n", 32)
+	req := httptest.NewRequest(http.MethodPost, "/todos/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	app.importTodosHandler(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusRequestEntityTooLarge, rec.Body)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// wsWriteWait is how long a single frame may take to write.
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long the client has to answer a ping before it's dropped.
+	wsPongWait = 60 * time.Second
+	// wsPingInterval must be shorter than wsPongWait so pongs arrive in time.
+	wsPingInterval = wsPongWait * 9 / 10
+)
+
+// upgrader accepts WebSocket connections from any origin, in line with the
+// Access-Control-Allow-Origin: * sent on the REST endpoints.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// todoWebSocketHandler handles GET /todos/ws, the WebSocket counterpart of
+// todoEventsHandler: each of the owner's todo events is sent as a JSON text frame.
+// The connection is receive-only; anything the client sends is discarded.
+func (app *application) todoWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	if app.nc == nil {
+		app.serviceUnavailableResponse(w, r, "event stream is unavailable")
+		return
+	}
+
+	// Subscribe before upgrading so a failure can still be reported as an HTTP error
+	msgs := make(chan *nats.Msg, 64)
+	sub, err := app.nc.ChanSubscribe(app.config.stream.Subject, msgs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	// Reading is what processes pongs and close frames, and a read error is how a
+	// disconnect is noticed, so keep a reader running for the connection's lifetime.
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	owner := app.owner(r)
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-disconnected:
+			return
+		case <-app.closing:
+			// Hijacked connections aren't closed by Shutdown, so say goodbye ourselves
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(wsWriteWait))
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case msg := <-msgs:
+			var event TodoMessage
+			if err := json.Unmarshal(msg.Data, &event); err != nil || event.Owner != owner {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.TextMessage, msg.Data); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -3,14 +3,21 @@ module todo-backend
 go 1.24.5
 
 require (
+	github.com/gorilla/websocket v1.5.3
 	github.com/lib/pq v1.10.9
 	github.com/nats-io/nats.go v1.46.1
+	github.com/swaggest/swgui v1.8.5
+	golang.org/x/time v0.9.0
+	streamconfig v0.0.0
 )
 
 require (
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/nats-io/nkeys v0.4.11 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
+	github.com/vearutop/statigz v1.4.0 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 )
+
+replace streamconfig => ../streamconfig
@@ -0,0 +1,78 @@
+// Package auth verifies the JWT bearer tokens accepted by the API.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for any token that fails to parse or verify. The
+// reason is deliberately not exposed to clients.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims holds the registered claims the API cares about. Exp and Nbf are NumericDate
+// values (seconds since the epoch) and are optional.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+}
+
+// ParseHS256 verifies a compact-serialized JWT signed with HMAC-SHA256 using secret
+// and returns its claims. Tokens using any other algorithm, past their exp, before
+// their nbf, or without a subject are rejected.
+func ParseHS256(token string, secret []byte, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	unix := float64(now.Unix())
+	if claims.ExpiresAt != nil && unix >= *claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+	if claims.NotBefore != nil && unix < *claims.NotBefore {
+		return nil, ErrInvalidToken
+	}
+	if claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// decodeSegment base64url-decodes a token segment and unmarshals its JSON into dst.
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event is an entry in the audit log of todo events. Rows are never purged along
+// with the todo or the JetStream stream, so they keep a todo's full history.
+type Event struct {
+	ID        int64           `json:"id"`
+	Action    string          `json:"action"`
+	TodoID    int             `json:"todo_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RecordEvent appends an event to the audit log. Call it on a store returned by
+// WithTx so the entry is only kept if the todo write commits.
+func (ts *TodoStore) RecordEvent(ctx context.Context, action string, todoID int, owner string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO events (action, todo_id, owner, payload)
+		VALUES ($1, $2, $3, $4)`
+
+	_, err := ts.db.ExecContext(ctx, query, action, todoID, owner, payload)
+	return err
+}
+
+// EventsForTodo returns owner's audit log entries for a todo, oldest first.
+func (ts *TodoStore) EventsForTodo(ctx context.Context, owner string, todoID int) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, action, todo_id, payload, created_at
+		FROM events
+		WHERE owner = $1 AND todo_id = $2
+		ORDER BY id`
+
+	rows, err := ts.db.QueryContext(ctx, query, owner, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.ID, &event.Action, &event.TodoID, &event.Payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
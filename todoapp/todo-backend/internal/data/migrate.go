@@ -0,0 +1,104 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrationLockID is the Postgres advisory lock key held while migrating, so that
+// several replicas starting at once don't apply the same migration concurrently.
+const migrationLockID = 7355608
+
+// Migrate applies the embedded SQL migrations in filename order, skipping versions
+// already recorded in the schema_migrations table. Each migration runs in its own
+// transaction together with its schema_migrations row, so a failure part-way through
+// leaves the database at the last fully applied version.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied := make(map[string]bool)
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// ReadDir returns entries sorted by filename, which is the order we apply them in.
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		version := strings.TrimSuffix(entry.Name(), ".sql")
+		if applied[version] {
+			continue
+		}
+
+		script, err := migrationFS.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return err
+		}
+
+		if err := applyMigration(ctx, conn, version, string(script)); err != nil {
+			return fmt.Errorf("migration %s failed: %w", version, err)
+		}
+		log.Printf("Applied migration %s", version)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, version, script string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
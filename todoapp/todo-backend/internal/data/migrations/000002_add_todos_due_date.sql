@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS due_date TIMESTAMP;
@@ -0,0 +1,2 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS idempotency_key TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS todos_idempotency_key_idx ON todos (idempotency_key);
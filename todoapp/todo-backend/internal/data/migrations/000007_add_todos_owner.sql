@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT 'public';
+CREATE INDEX IF NOT EXISTS todos_owner_idx ON todos (owner);
+DROP INDEX IF EXISTS todos_idempotency_key_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS todos_owner_idempotency_key_idx ON todos (owner, idempotency_key);
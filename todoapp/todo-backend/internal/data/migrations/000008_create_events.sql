@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS events (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    todo_id INTEGER NOT NULL,
+    owner TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS events_todo_id_idx ON events (todo_id, id);
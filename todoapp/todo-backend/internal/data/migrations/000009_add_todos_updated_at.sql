@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+UPDATE todos SET updated_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE updated_at IS NULL;
+ALTER TABLE todos ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE todos ALTER COLUMN updated_at SET NOT NULL;
@@ -0,0 +1,104 @@
+package data
+
+import (
+	"context"
+	"errors"
+)
+
+// OutboxEvent is an event recorded in the same transaction as the todo write that
+// produced it, waiting to be published to JetStream.
+type OutboxEvent struct {
+	ID        int64
+	Payload   []byte
+	RequestID string
+	Attempts  int
+}
+
+// EnqueueEvent records an event in the outbox. Call it on a store returned by WithTx
+// so the event is only persisted if the todo write commits. requestID identifies the
+// API call that produced the event and may be empty.
+func (ts *TodoStore) EnqueueEvent(ctx context.Context, payload []byte, requestID string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, err := ts.db.ExecContext(ctx, "INSERT INTO outbox (payload, request_id) VALUES ($1, NULLIF($2, ''))", payload, requestID)
+	return err
+}
+
+// DrainOutbox hands up to limit pending events, oldest first, to publish, which
+// returns one error per event it attempted, in order. Events published without error
+// are deleted and failures are recorded against their event; events past the end of
+// the returned errors weren't attempted and are left as they are. It returns the
+// number of events published and the first failure. Rows are locked with SKIP
+// LOCKED, so several replicas can drain concurrently without publishing an event
+// twice.
+func (ts *TodoStore) DrainOutbox(ctx context.Context, limit int, publish func([]OutboxEvent) []error) (int, error) {
+	if ts.pool == nil {
+		return 0, errors.New("DrainOutbox cannot run inside a transaction")
+	}
+
+	tx, err := ts.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, payload, COALESCE(request_id, ''), attempts 
+		FROM outbox 
+		ORDER BY id 
+		LIMIT $1 
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		if err := rows.Scan(&event.ID, &event.Payload, &event.RequestID, &event.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	published := 0
+	var publishErr error
+	for i, failure := range publish(events) {
+		event := events[i]
+		if failure != nil {
+			if publishErr == nil {
+				publishErr = failure
+			}
+			_, err := tx.ExecContext(ctx,
+				"UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = $2",
+				failure.Error(), event.ID)
+			if err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM outbox WHERE id = $1", event.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return published, publishErr
+}
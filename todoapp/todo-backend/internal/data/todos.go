@@ -1,43 +1,392 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"todo-backend/internal/validator"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
 )
 
+// MaxTitleLength is the longest title (in characters) the frontend lets users enter.
+// It must stay within the title column's VARCHAR(255).
+const MaxTitleLength = 140
+
+// DefaultMaxDescriptionLength is the longest description (in characters) accepted
+// unless MAX_DESCRIPTION_LEN says otherwise.
+const DefaultMaxDescriptionLength = 140
+
+// queryTimeout bounds every store query so a hung connection can't block a handler
+// until the server's write timeout fires.
+const queryTimeout = 3 * time.Second
+
+// ErrRecordNotFound is returned when a query matches no todo.
+var ErrRecordNotFound = errors.New("record not found")
+
+// PublicOwner owns every todo when authentication is disabled, so all clients share
+// a single list as they did before todos were scoped to owners.
+const PublicOwner = "public"
+
 type Todo struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int        `json:"id"`
+	Owner       string     `json:"owner"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    int        `json:"priority"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Todo priorities, stored in the priority SMALLINT column
+const (
+	PriorityLow = iota
+	PriorityMedium
+	PriorityHigh
+)
+
+// SortSafelist holds the values accepted for the sort query parameter.
+var SortSafelist = []string{"created_at", "priority"}
+
+// Filters holds the list options accepted by GetAll
+type Filters struct {
+	Sort           string
+	IncludeDeleted bool
+}
+
+// todoColumns lists the columns every todo query returns, in the order scanTodo
+// reads them.
+const todoColumns = "id, owner, title, description, completed, due_date, priority, created_at, updated_at, deleted_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTodo(row rowScanner, todo *Todo) error {
+	return row.Scan(&todo.ID, &todo.Owner, &todo.Title, &todo.Description, &todo.Completed, &todo.DueDate, &todo.Priority, &todo.CreatedAt, &todo.UpdatedAt, &todo.DeletedAt)
+}
+
+// TodoStats holds aggregate counts over all todos
+type TodoStats struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Pending   int `json:"pending"`
+}
+
+// TodoUpdate holds the fields of a partial update. Nil fields are left unchanged;
+// ClearDueDate sets the due date to NULL instead.
+type TodoUpdate struct {
+	Title        *string
+	Completed    *bool
+	DueDate      *time.Time
+	ClearDueDate bool
+	Priority     *int
+}
+
+// NormalizeTitle trims surrounding whitespace so that "  hi  " and "hi" are stored
+// as the same title.
+func NormalizeTitle(title string) string {
+	return strings.TrimSpace(title)
+}
+
+// ValidateTitle checks an already-normalized title: 1-140 characters and no control
+// characters.
+func ValidateTitle(v *validator.Validator, title string) {
+	v.Check(title != "", "title", "title is required")
+	v.Check(utf8.RuneCountInString(title) <= MaxTitleLength, "title", fmt.Sprintf("title cannot exceed %d characters", MaxTitleLength))
+	v.Check(!strings.ContainsFunc(title, unicode.IsControl), "title", "title cannot contain control characters")
+}
+
+// ValidateDescription checks that a description is present and at most maxLength
+// characters.
+func ValidateDescription(v *validator.Validator, description string, maxLength int) {
+	v.Check(description != "", "description", "Description is required")
+	v.Check(utf8.RuneCountInString(description) <= maxLength, "description", fmt.Sprintf("Description cannot exceed %d characters", maxLength))
+}
+
+// ValidatePriority checks that a priority is one of the known levels.
+func ValidatePriority(v *validator.Validator, priority int) {
+	v.Check(priority >= PriorityLow && priority <= PriorityHigh, "priority", "priority must be 0 (low), 1 (medium) or 2 (high)")
+}
+
+// ValidateFilters checks the list options against SortSafelist.
+func ValidateFilters(v *validator.Validator, f Filters) {
+	v.Check(validator.In(f.Sort, SortSafelist...), "sort", "sort must be one of: "+strings.Join(SortSafelist, ", "))
+}
+
+// orderBy maps a validated sort key to its ORDER BY clause.
+func (f Filters) orderBy() string {
+	switch f.Sort {
+	case "priority":
+		return "priority DESC, created_at DESC"
+	default:
+		return "created_at DESC"
+	}
+}
+
+// dbtx is implemented by both *sql.DB and *sql.Tx, so the same store methods can
+// run either on their own or as part of a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 // TodoStore handles PostgreSQL storage of todos
 type TodoStore struct {
-	db *sql.DB
+	db   dbtx
+	pool *sql.DB // nil for a store bound to a transaction
 }
 
 // NewTodoStore creates a new todo store with database connection
 func NewTodoStore(db *sql.DB) TodoStore {
-	return TodoStore{db: db}
+	return TodoStore{db: db, pool: db}
+}
+
+// ErrQuotaExceeded is returned by CheckQuota when an owner has more todos than allowed.
+var ErrQuotaExceeded = errors.New("todo quota exceeded")
+
+// CheckQuota returns ErrQuotaExceeded if owner has more than max non-deleted todos.
+// Call it on a store returned by WithTx after inserting, so the error rolls the
+// insert back. It takes a per-owner advisory lock held until the transaction ends,
+// so concurrent creates for the same owner are counted one after another and can't
+// both slip past the limit.
+func (ts *TodoStore) CheckQuota(ctx context.Context, owner string, max int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := ts.db.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", owner); err != nil {
+		return err
+	}
+
+	var count int
+	query := "SELECT COUNT(*) FROM todos WHERE owner = $1 AND deleted_at IS NULL"
+	if err := ts.db.QueryRowContext(ctx, query, owner).Scan(&count); err != nil {
+		return err
+	}
+	if count > max {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// WithTx runs fn with a store bound to a single transaction, committing if fn
+// returns nil and rolling back otherwise.
+func (ts *TodoStore) WithTx(ctx context.Context, fn func(tx *TodoStore) error) error {
+	if ts.pool == nil {
+		return errors.New("nested transactions are not supported")
+	}
+
+	sqlTx, err := ts.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer sqlTx.Rollback()
+
+	if err := fn(&TodoStore{db: sqlTx}); err != nil {
+		return err
+	}
+
+	return sqlTx.Commit()
 }
 
-// GetAll returns all todos from database
-func (ts *TodoStore) GetAll() ([]Todo, error) {
-	query := "SELECT id, title, description, completed, created_at FROM todos ORDER BY created_at DESC"
-	rows, err := ts.db.Query(query)
+// GetAll returns all todos belonging to owner
+func (ts *TodoStore) GetAll(ctx context.Context, owner string, filters Filters) ([]Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var todos []Todo
+	err := ts.Each(ctx, owner, filters, func(todo *Todo) error {
+		todos = append(todos, *todo)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	return todos, nil
+}
+
+// Each calls fn for every todo belonging to owner, one row at a time, so large lists
+// can be streamed without holding them in memory. Iteration stops at the first error
+// fn returns. Unlike the other queries, Each is bounded only by ctx.
+func (ts *TodoStore) Each(ctx context.Context, owner string, filters Filters, fn func(*Todo) error) error {
+	query := "SELECT " + todoColumns + " FROM todos WHERE owner = $1"
+	if !filters.IncludeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
+	query += " ORDER BY " + filters.orderBy()
+	rows, err := ts.db.QueryContext(ctx, query, owner)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
-	var todos []Todo
 	for rows.Next() {
 		var todo Todo
-		err := rows.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt)
-		if err != nil {
+		if err := scanTodo(rows, &todo); err != nil {
+			return err
+		}
+		if err := fn(&todo); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetByID returns a single todo belonging to owner. Soft-deleted todos and todos of
+// other owners are reported as ErrRecordNotFound.
+func (ts *TodoStore) GetByID(ctx context.Context, owner string, id int) (*Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "SELECT " + todoColumns + " FROM todos WHERE id = $1 AND owner = $2 AND deleted_at IS NULL"
+
+	var todo Todo
+	err := scanTodo(ts.db.QueryRowContext(ctx, query, id, owner), &todo)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// Stats returns the total, completed and pending counts of owner's todos in a
+// single query
+func (ts *TodoStore) Stats(ctx context.Context, owner string) (*TodoStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "SELECT count(*) FILTER (WHERE completed) AS done, count(*) AS total FROM todos WHERE owner = $1 AND deleted_at IS NULL"
+
+	var stats TodoStats
+	err := ts.db.QueryRowContext(ctx, query, owner).Scan(&stats.Completed, &stats.Total)
+	if err != nil {
+		return nil, err
+	}
+	stats.Pending = stats.Total - stats.Completed
+
+	return &stats, nil
+}
+
+// Create inserts a new todo for todo.Owner, filling in the ID and timestamps assigned
+// by the database
+func (ts *TodoStore) Create(ctx context.Context, todo *Todo) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO todos (owner, title, description, completed, due_date, priority, created_at, updated_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7) 
+		RETURNING ` + todoColumns
+
+	row := ts.db.QueryRowContext(ctx, query, todo.Owner, todo.Title, todo.Description, todo.Completed, todo.DueDate, todo.Priority, time.Now())
+	return scanTodo(row, todo)
+}
+
+// CreateMany inserts several todos, filling in the IDs and timestamps assigned by the
+// database. Call it on a store bound to a transaction so that either all of them
+// are inserted or none are.
+func (ts *TodoStore) CreateMany(ctx context.Context, todos []*Todo) error {
+	for _, todo := range todos {
+		if err := ts.Create(ctx, todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateIdempotent inserts a new todo tagged with a client-supplied idempotency key.
+// Keys are scoped to todo.Owner. If a todo with the same key already exists (including one inserted concurrently by
+// a racing request), todo is filled with that existing row and created is false.
+func (ts *TodoStore) CreateIdempotent(ctx context.Context, todo *Todo, key string) (created bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO todos (owner, title, description, completed, due_date, priority, created_at, updated_at, idempotency_key) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $8) 
+		ON CONFLICT (owner, idempotency_key) DO NOTHING 
+		RETURNING ` + todoColumns
+
+	args := []any{todo.Owner, todo.Title, todo.Description, todo.Completed, todo.DueDate, todo.Priority, time.Now(), key}
+
+	err = scanTodo(ts.db.QueryRowContext(ctx, query, args...), todo)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	// The insert hit the unique index, so re-select the row that owns the key.
+	query = "SELECT " + todoColumns + " FROM todos WHERE owner = $1 AND idempotency_key = $2"
+	if err := scanTodo(ts.db.QueryRowContext(ctx, query, todo.Owner, key), todo); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// Toggle inverts the completion status of one of owner's todos and returns the
+// updated row. It returns ErrRecordNotFound if there is no such todo.
+func (ts *TodoStore) Toggle(ctx context.Context, owner string, id int) (*Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE todos 
+		SET completed = NOT completed, updated_at = CURRENT_TIMESTAMP 
+		WHERE id = $1 AND owner = $2 AND deleted_at IS NULL 
+		RETURNING ` + todoColumns
+
+	var todo Todo
+	err := scanTodo(ts.db.QueryRowContext(ctx, query, id, owner), &todo)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// CompleteMany marks every todo of owner whose id is in ids as completed and returns
+// the updated rows. Ids that match no todo are simply absent from the result.
+func (ts *TodoStore) CompleteMany(ctx context.Context, owner string, ids []int) ([]Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE todos 
+		SET completed = true, updated_at = CURRENT_TIMESTAMP 
+		WHERE id = ANY($1) AND owner = $2 AND deleted_at IS NULL 
+		RETURNING ` + todoColumns
+
+	rows, err := ts.db.QueryContext(ctx, query, pq.Array(ids), owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []Todo{}
+	for rows.Next() {
+		var todo Todo
+		if err := scanTodo(rows, &todo); err != nil {
 			return nil, err
 		}
 		todos = append(todos, todo)
@@ -50,57 +399,144 @@ func (ts *TodoStore) GetAll() ([]Todo, error) {
 	return todos, nil
 }
 
-// Create adds a new todo to database
-func (ts *TodoStore) Create(title, description string) (*Todo, error) {
+// UpdateFields applies a partial update to one of owner's todos, leaving nil fields
+// untouched
+func (ts *TodoStore) UpdateFields(ctx context.Context, owner string, id int, fields TodoUpdate) (*Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO todos (title, description, completed, created_at) 
-		VALUES ($1, $2, $3, $4) 
-		RETURNING id, title, description, completed, created_at`
+		UPDATE todos 
+		SET title = COALESCE($1, title), 
+			completed = COALESCE($2, completed), 
+			due_date = CASE WHEN $7 THEN NULL ELSE COALESCE($3, due_date) END, 
+			priority = COALESCE($4, priority), 
+			updated_at = CURRENT_TIMESTAMP 
+		WHERE id = $5 AND owner = $6 AND deleted_at IS NULL 
+		RETURNING ` + todoColumns
+
+	args := []any{fields.Title, fields.Completed, fields.DueDate, fields.Priority, id, owner, fields.ClearDueDate}
 
 	var todo Todo
-	err := ts.db.QueryRow(query, title, description, false, time.Now()).Scan(
-		&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt)
+	err := scanTodo(ts.db.QueryRowContext(ctx, query, args...), &todo)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
 		return nil, err
 	}
 
 	return &todo, nil
 }
 
-// Update updates a todo's completion status
-func (ts *TodoStore) Update(id int, completed bool) (*Todo, error) {
+// PurgeCompleted permanently deletes completed todos created more than olderThan ago
+// and returns how many rows were removed
+func (ts *TodoStore) PurgeCompleted(ctx context.Context, olderThan time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "DELETE FROM todos WHERE completed AND created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'"
+	result, err := ts.db.ExecContext(ctx, query, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// PurgeDeleted permanently deletes todos that were soft-deleted more than olderThan
+// ago and returns how many rows were removed
+func (ts *TodoStore) PurgeDeleted(ctx context.Context, olderThan time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "DELETE FROM todos WHERE deleted_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'"
+	result, err := ts.db.ExecContext(ctx, query, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Delete soft-deletes one of owner's todos by setting its deleted_at timestamp, so it
+// can still be restored until the purge job removes it
+func (ts *TodoStore) Delete(ctx context.Context, owner string, id int) (*Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
 	query := `
 		UPDATE todos 
-		SET completed = $1 
-		WHERE id = $2 
-		RETURNING id, title, description, completed, created_at`
+		SET deleted_at = $1, updated_at = $1 
+		WHERE id = $2 AND owner = $3 AND deleted_at IS NULL 
+		RETURNING ` + todoColumns
 
 	var todo Todo
-	err := ts.db.QueryRow(query, completed, id).Scan(
-		&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt)
+	err := scanTodo(ts.db.QueryRowContext(ctx, query, time.Now(), id, owner), &todo)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
 		return nil, err
 	}
 
 	return &todo, nil
 }
 
-// Delete removes a todo from database
-func (ts *TodoStore) Delete(id int) error {
-	query := "DELETE FROM todos WHERE id = $1"
-	result, err := ts.db.Exec(query, id)
+// DeleteCompleted soft-deletes all of owner's completed todos in one statement and
+// returns them.
+func (ts *TodoStore) DeleteCompleted(ctx context.Context, owner string) ([]Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE todos 
+		SET deleted_at = $1, updated_at = $1 
+		WHERE owner = $2 AND completed AND deleted_at IS NULL 
+		RETURNING ` + todoColumns
+
+	rows, err := ts.db.QueryContext(ctx, query, time.Now(), owner)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+	todos := []Todo{}
+	for rows.Next() {
+		var todo Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("todo with id %d not found", id)
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return todos, nil
+}
+
+// Restore undoes a soft delete of one of owner's todos. It returns ErrRecordNotFound
+// if the todo doesn't exist or isn't deleted.
+func (ts *TodoStore) Restore(ctx context.Context, owner string, id int) (*Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE todos 
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP 
+		WHERE id = $1 AND owner = $2 AND deleted_at IS NOT NULL 
+		RETURNING ` + todoColumns
+
+	var todo Todo
+	err := scanTodo(ts.db.QueryRowContext(ctx, query, id, owner), &todo)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &todo, nil
 }
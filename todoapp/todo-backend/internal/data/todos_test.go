@@ -0,0 +1,41 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"todo-backend/internal/validator"
+)
+
+func TestValidateTitleTooLong(t *testing.T) {
+	// One more character than the title column's VARCHAR(255) holds
+	v := validator.New()
+	ValidateTitle(v, strings.Repeat("a", 256))
+
+	if _, ok := v.Errors["title"]; !ok {
+		t.Fatalf("errors = %v, want an error on title", v.Errors)
+	}
+}
+
+func TestValidateDescriptionLength(t *testing.T) {
+	const maxLength = 5
+	tests := []struct {
+		name        string
+		description string
+		valid       bool
+	}{
+		// "é" is two bytes, so a byte count would reject both
+		{"at the limit", strings.Repeat("é", maxLength), true},
+		{"one over the limit", strings.Repeat("é", maxLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateDescription(v, tt.description, maxLength)
+
+			if _, invalid := v.Errors["description"]; invalid == tt.valid {
+				t.Errorf("errors = %v, want valid = %v", v.Errors, tt.valid)
+			}
+		})
+	}
+}